@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is the error returned for the requests to a host whose circuit is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// breakerOptions holds the circuit breaker settings collected by the options.
+type breakerOptions struct {
+	threshold int
+	cooldown  time.Duration
+}
+
+// breaker is the circuit of a host. It is open while failures reach the threshold and openUntil
+// is in the future. Once it expires, a single probe request is let through: the circuit closes
+// if it succeeds and opens again otherwise.
+type breaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// breakerTransport fails fast the requests to the hosts that keep failing.
+type breakerTransport struct {
+	next http.RoundTripper
+	opts breakerOptions
+	now  func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func newBreakerTransport(next http.RoundTripper, opts breakerOptions) *breakerTransport {
+	return &breakerTransport{
+		next:  next,
+		opts:  opts,
+		now:   time.Now,
+		hosts: make(map[string]*breaker),
+	}
+}
+
+// RoundTrip sends the request unless the circuit of its host is open.
+// Network errors and 5xx responses count as failures.
+func (t *breakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := r.URL.Host
+
+	probe, ok := t.allow(host)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+
+	// A panicking transport is ignored, but it must still free the probe slot.
+	res := outcomeIgnored
+	defer func() {
+		t.record(host, probe, res)
+	}()
+
+	resp, err := t.next.RoundTrip(r)
+
+	switch {
+	case err != nil && r.Context().Err() != nil:
+		// A request cancelled by the caller says nothing about the host.
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		res = outcomeFailure
+	default:
+		res = outcomeSuccess
+	}
+
+	return resp, err
+}
+
+// outcome is the outcome of a request recorded by the circuit breaker.
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored
+)
+
+// allow reports whether a request to the host can be sent and whether it is the probe of an open circuit.
+func (t *breakerTransport) allow(host string) (probe, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, exists := t.hosts[host]
+	if !exists {
+		b = &breaker{}
+		t.hosts[host] = b
+	}
+
+	if b.failures < t.opts.threshold {
+		return false, true
+	}
+
+	if t.now().Before(b.openUntil) || b.probing {
+		return false, false
+	}
+
+	b.probing = true
+
+	return true, true
+}
+
+// record updates the circuit of the host with the outcome of a request.
+// Only the probe frees the probe slot, so requests sent before the circuit opened
+// can not let a second probe through.
+func (t *breakerTransport) record(host string, probe bool, res outcome) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.hosts[host]
+	if probe {
+		b.probing = false
+	}
+
+	switch res {
+	case outcomeSuccess:
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+		if b.failures >= t.opts.threshold {
+			b.openUntil = t.now().Add(t.opts.cooldown)
+		}
+	case outcomeIgnored:
+	}
+}
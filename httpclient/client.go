@@ -0,0 +1,94 @@
+// Package httpclient provides an http.Client for outgoing calls with retries,
+// a per-host circuit breaker, request logging and trace propagation.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver/trace"
+)
+
+const (
+	defaultTimeout               = 10 * time.Second
+	defaultDialTimeout           = 5 * time.Second
+	defaultTLSHandshakeTimeout   = 5 * time.Second
+	defaultResponseHeaderTimeout = 5 * time.Second
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultMaxIdleConnsPerHost   = 16
+)
+
+// Middleware wraps an http.RoundTripper with additional behaviour.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use a function as an http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r).
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// New creates a new http.Client. Requests go through a chain of round trippers, from the outermost:
+// trace propagation, logging, retries, the middlewares added with Use, the circuit breaker and the transport.
+// The client timeout covers the whole call, including the retries.
+func New(opts ...Option) *http.Client {
+	o := &options{
+		timeout:               defaultTimeout,
+		dialTimeout:           defaultDialTimeout,
+		tlsHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		responseHeaderTimeout: defaultResponseHeaderTimeout,
+		retry: retryOptions{
+			max:        defaultMaxRetries,
+			baseDelay:  defaultBaseDelay,
+			maxDelay:   defaultMaxDelay,
+			retryAfter: true,
+		},
+		breaker: breakerOptions{
+			threshold: defaultBreakerThreshold,
+			cooldown:  defaultBreakerCooldown,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rt := o.transport
+	if rt == nil {
+		rt = &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: o.dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   o.tlsHandshakeTimeout,
+			ResponseHeaderTimeout: o.responseHeaderTimeout,
+			IdleConnTimeout:       defaultIdleConnTimeout,
+			MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+			ExpectContinueTimeout: time.Second,
+		}
+	}
+
+	if o.breaker.threshold > 0 {
+		rt = newBreakerTransport(rt, o.breaker)
+	}
+
+	for i := len(o.middlewares) - 1; i >= 0; i-- {
+		rt = o.middlewares[i](rt)
+	}
+
+	if o.retry.max > 0 {
+		rt = newRetryTransport(rt, o.retry)
+	}
+
+	if o.logger != nil {
+		rt = logTransport(rt, o.logger)
+	}
+
+	rt = trace.Transport(rt)
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   o.timeout,
+	}
+}
@@ -0,0 +1,365 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer fails the first n requests with the status and records the bodies it receives.
+func flakyServer(t *testing.T, n int32, status int) (*httptest.Server, *atomic.Int32, *[]string) {
+	t.Helper()
+
+	var (
+		calls  atomic.Int32
+		bodies []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		if calls.Add(1) <= n {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls, &bodies
+}
+
+func Test_Client_retries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		fails  int32
+		status int
+		calls  int32
+		code   int
+	}{
+		{name: "get", method: http.MethodGet, fails: 2, status: http.StatusServiceUnavailable, calls: 3, code: http.StatusOK},
+		{name: "exhausted", method: http.MethodGet, fails: 5, status: http.StatusBadGateway, calls: 3, code: http.StatusBadGateway},
+		{name: "not transient", method: http.MethodGet, fails: 1, status: http.StatusInternalServerError, calls: 1, code: http.StatusInternalServerError},
+		{name: "post", method: http.MethodPost, fails: 1, status: http.StatusServiceUnavailable, calls: 1, code: http.StatusServiceUnavailable},
+		{name: "post with key", method: http.MethodPost, key: "k1", fails: 1, status: http.StatusServiceUnavailable, calls: 2, code: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, calls, bodies := flakyServer(t, tc.fails, tc.status)
+			client := New(Backoff(time.Millisecond, 10*time.Millisecond), CircuitBreaker(0, 0))
+
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader("payload"))
+			require.NoError(t, err)
+
+			if tc.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tc.key)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tc.code, resp.StatusCode)
+			assert.Equal(t, tc.calls, calls.Load())
+
+			for _, body := range *bodies {
+				assert.Equal(t, "payload", body, "body is sent again")
+			}
+		})
+	}
+}
+
+func Test_Client_retryAfterTooLong(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	resp, err := New().Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// fakeHost is a round tripper failing while fail is set and panicking while panics is set.
+// Requests with the X-Block header wait until the gate named by the header is closed.
+type fakeHost struct {
+	fail    atomic.Bool
+	panics  atomic.Bool
+	calls   atomic.Int32
+	started chan struct{}
+	gates   map[string]chan struct{}
+}
+
+func newFakeHost() *fakeHost {
+	h := &fakeHost{
+		started: make(chan struct{}),
+		gates:   map[string]chan struct{}{"stale": make(chan struct{}), "probe": make(chan struct{})},
+	}
+	h.fail.Store(true)
+
+	return h
+}
+
+func (h *fakeHost) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.calls.Add(1)
+
+	if gate := r.Header.Get("X-Block"); gate != "" {
+		h.started <- struct{}{}
+		<-h.gates[gate]
+	}
+
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	if h.panics.Load() {
+		panic("fake host")
+	}
+
+	if h.fail.Load() {
+		return nil, errors.New("connection refused")
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+func Test_breakerTransport(t *testing.T) {
+	t.Parallel()
+
+	setup := func() (*fakeHost, func(ctx context.Context, host, gate string) error, func(time.Duration)) {
+		h := newFakeHost()
+
+		var (
+			mu  sync.Mutex
+			now = time.Now()
+		)
+
+		bt := newBreakerTransport(h, breakerOptions{threshold: 2, cooldown: time.Minute})
+		bt.now = func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return now
+		}
+
+		do := func(ctx context.Context, host, gate string) error {
+			req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil).WithContext(ctx)
+			if gate != "" {
+				req.Header.Set("X-Block", gate)
+			}
+
+			resp, err := bt.RoundTrip(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			return err
+		}
+
+		advance := func(d time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			now = now.Add(d)
+		}
+
+		return h, do, advance
+	}
+
+	ctx := context.Background()
+
+	t.Run("open and close", func(t *testing.T) {
+		t.Parallel()
+
+		h, do, advance := setup()
+
+		require.Error(t, do(ctx, "a", ""))
+		require.Error(t, do(ctx, "a", ""))
+		require.ErrorIs(t, do(ctx, "a", ""), ErrCircuitOpen)
+		assert.Equal(t, int32(2), h.calls.Load(), "open circuit fails fast")
+
+		h.fail.Store(false)
+		require.NoError(t, do(ctx, "b", ""), "circuits are per host")
+
+		advance(time.Minute)
+		require.NoError(t, do(ctx, "a", ""), "probe is let through")
+		require.NoError(t, do(ctx, "a", ""), "circuit is closed")
+	})
+
+	t.Run("cancelled probe", func(t *testing.T) {
+		t.Parallel()
+
+		h, do, advance := setup()
+
+		require.Error(t, do(ctx, "a", ""))
+		require.Error(t, do(ctx, "a", ""))
+		advance(time.Minute)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.ErrorIs(t, do(cancelled, "a", ""), context.Canceled)
+
+		require.Error(t, do(ctx, "a", ""), "circuit is still half-open")
+		require.ErrorIs(t, do(ctx, "a", ""), ErrCircuitOpen, "failed probe opens the circuit again")
+		assert.Equal(t, int32(4), h.calls.Load())
+	})
+
+	t.Run("panicking probe", func(t *testing.T) {
+		t.Parallel()
+
+		h, do, advance := setup()
+
+		require.Error(t, do(ctx, "a", ""))
+		require.Error(t, do(ctx, "a", ""))
+		advance(time.Minute)
+
+		h.panics.Store(true)
+		assert.Panics(t, func() { _ = do(ctx, "a", "") })
+
+		h.panics.Store(false)
+		h.fail.Store(false)
+		require.NoError(t, do(ctx, "a", ""), "next probe is let through")
+	})
+
+	t.Run("cancelled requests keep failures", func(t *testing.T) {
+		t.Parallel()
+
+		_, do, _ := setup()
+
+		require.Error(t, do(ctx, "a", ""))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.ErrorIs(t, do(cancelled, "a", ""), context.Canceled)
+
+		require.Error(t, do(ctx, "a", ""))
+		require.ErrorIs(t, do(ctx, "a", ""), ErrCircuitOpen)
+	})
+
+	t.Run("stale completion", func(t *testing.T) {
+		t.Parallel()
+
+		h, do, advance := setup()
+
+		stale := make(chan error, 1)
+		go func() {
+			stale <- do(ctx, "a", "stale")
+		}()
+		<-h.started
+
+		require.Error(t, do(ctx, "a", ""))
+		require.Error(t, do(ctx, "a", ""))
+		advance(time.Minute)
+
+		probe := make(chan error, 1)
+		go func() {
+			probe <- do(ctx, "a", "probe")
+		}()
+		<-h.started
+
+		// The stale request finishes while the probe is in flight.
+		close(h.gates["stale"])
+		require.Error(t, <-stale)
+
+		require.ErrorIs(t, do(ctx, "a", ""), ErrCircuitOpen, "only one probe is let through")
+
+		h.fail.Store(false)
+		close(h.gates["probe"])
+		require.NoError(t, <-probe)
+		require.NoError(t, do(ctx, "a", ""), "circuit is closed")
+	})
+}
+
+func Test_Client_traceAndLog(t *testing.T) {
+	t.Parallel()
+
+	var traceparent string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(trace.TraceparentHeader)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+
+	client := New(Logger(slog.New(trace.NewLogHandler(slog.NewJSONHandler(&buf, nil)))))
+
+	sc := trace.NewSpan(trace.SpanContext{})
+	req, err := http.NewRequestWithContext(trace.ContextWithSpan(context.Background(), sc), http.MethodGet, srv.URL+"/users?token=secret", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, sc.Traceparent(), traceparent)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+	assert.Equal(t, "outgoing request", record["msg"])
+	assert.Equal(t, "/users", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, sc.TraceID.String(), record["trace_id"])
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{value: "3", want: 3 * time.Second, ok: true},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, ok: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, ok: true},
+		{value: "-1"},
+		{value: "soon"},
+		{value: ""},
+	}
+
+	for _, tc := range tests {
+		got, ok := parseRetryAfter(tc.value, now)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.want, got, tc.value)
+	}
+}
@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// logTransport logs every request once the response headers are received.
+// Failed requests and 5xx responses are logged with the error level, others with the info level.
+// The query is left out of the log, since it may hold credentials.
+func logTransport(next http.RoundTripper, logger *slog.Logger) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+
+		resp, err := next.RoundTrip(r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("host", r.URL.Host),
+			slog.String("path", r.URL.Path),
+			slog.Duration("latency", time.Since(start)),
+		}
+
+		level := slog.LevelInfo
+
+		if err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", err.Error()))
+		} else {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		}
+
+		logger.LogAttrs(r.Context(), level, "outgoing request", attrs...)
+
+		return resp, err
+	})
+}
@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// options holds the settings collected by the options.
+type options struct {
+	timeout               time.Duration
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	transport             http.RoundTripper
+	middlewares           []Middleware
+	retry                 retryOptions
+	breaker               breakerOptions
+	logger                *slog.Logger
+}
+
+type Option func(o *options)
+
+// Timeout limits the duration of a call, including the retries. Zero means no timeout.
+func Timeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// DialTimeout limits the time to establish a connection.
+func DialTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.dialTimeout = timeout
+	}
+}
+
+// TLSHandshakeTimeout limits the time of the TLS handshake.
+func TLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.tlsHandshakeTimeout = timeout
+	}
+}
+
+// ResponseHeaderTimeout limits the time to wait for the response headers after the request is written.
+func ResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.responseHeaderTimeout = timeout
+	}
+}
+
+// Transport sets the round tripper sending the requests. The timeout options other than Timeout
+// only apply to the default transport.
+func Transport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+// Use adds middlewares wrapping every attempt of a request. They are applied in the order given,
+// the first one being the outermost.
+func Use(mws ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// Retries sets the maximum number of retries of a request. Zero disables the retries.
+func Retries(n int) Option {
+	return func(o *options) {
+		o.retry.max = n
+	}
+}
+
+// Backoff sets the base and maximum delay between retries. The delay before the n-th retry is random
+// between zero and base * 2^(n-1), capped at maxDelay. A Retry-After longer than maxDelay ends the retries.
+func Backoff(base, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.retry.baseDelay = base
+		o.retry.maxDelay = maxDelay
+	}
+}
+
+// IgnoreRetryAfter makes the retries use the backoff delay instead of the Retry-After header of the response.
+func IgnoreRetryAfter() Option {
+	return func(o *options) {
+		o.retry.retryAfter = false
+	}
+}
+
+// CircuitBreaker sets the number of consecutive failures that open the circuit of a host
+// and how long it stays open. A zero threshold disables the circuit breaker.
+func CircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.breaker.threshold = threshold
+		o.breaker.cooldown = cooldown
+	}
+}
+
+// Logger enables the logging of every request with logger.
+func Logger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
@@ -0,0 +1,184 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 2
+	defaultBaseDelay  = 100 * time.Millisecond
+	defaultMaxDelay   = 2 * time.Second
+
+	// IdempotencyKeyHeader is the header making a non-idempotent request safe to retry.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// maxDrainBytes limits the body read from a response that is retried, so its connection can be reused.
+	maxDrainBytes = 4 << 10
+)
+
+// retryOptions holds the retry settings collected by the options.
+type retryOptions struct {
+	max        int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	retryAfter bool
+}
+
+// retryTransport retries the requests that failed with a network error or a transient status.
+type retryTransport struct {
+	next http.RoundTripper
+	opts retryOptions
+	// jitter returns a random duration in [0, d].
+	jitter func(d time.Duration) time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper, opts retryOptions) *retryTransport {
+	return &retryTransport{
+		next: next,
+		opts: opts,
+		jitter: func(d time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(d) + 1))
+		},
+	}
+}
+
+// RoundTrip sends the request, retrying it if it is idempotent or carries an Idempotency-Key header
+// and its body can be sent again.
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.next.RoundTrip(r)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := rewind(r, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.opts.max || !shouldRetry(r.Context(), resp, err) {
+			return resp, err
+		}
+
+		delay, ok := t.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+			resp.Body.Close()
+		}
+
+		if err := sleep(r.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delay returns the delay before the retry following the attempt. The Retry-After header
+// of the response is used if present. It reports false if the server asks to wait longer than
+// the maximum delay.
+func (t *retryTransport) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if t.opts.retryAfter && resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, d <= t.opts.maxDelay
+		}
+	}
+
+	d := t.opts.baseDelay << attempt
+	if attempt >= 32 || d <= 0 || d > t.opts.maxDelay {
+		d = t.opts.maxDelay
+	}
+
+	return t.jitter(d), true
+}
+
+// retryable reports whether the request may be sent more than once.
+func retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		if r.Header.Get(IdempotencyKeyHeader) == "" {
+			return false
+		}
+	}
+
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// rewind returns the request to send for the attempt, with a fresh body for the retries.
+func rewind(r *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	req := r.Clone(r.Context())
+	req.Body = body
+
+	return req, nil
+}
+
+// shouldRetry reports whether the attempt failed with a network error or a transient status.
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, ErrCircuitOpen)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses the Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+// sleep waits for d or until the context is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
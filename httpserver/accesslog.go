@@ -0,0 +1,52 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog returns a middleware that logs every request once the response is written.
+// Responses with a 5xx status are logged with the error level, others with the info level.
+func AccessLog(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start = time.Now()
+				rw    = wrapResponseWriter(w)
+			)
+
+			r, holder := withRouteHolder(r)
+
+			next.ServeHTTP(rw, r)
+
+			level := slog.LevelInfo
+			if rw.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.Status()),
+				slog.Int64("bytes", rw.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+
+			if holder.name != "" {
+				attrs = append(attrs, slog.String("route", holder.name))
+			}
+
+			if id := RequestIDFromContext(r.Context()); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+const (
+	envListenFDs     = "LISTEN_FDS"
+	envListenPID     = "LISTEN_PID"
+	envListenFDNames = "LISTEN_FDNAMES"
+
+	// listenFDsStart is the first file descriptor passed by systemd, see sd_listen_fds(3).
+	listenFDsStart = 3
+
+	// listenerNameHTTP and listenerNameAdmin are the LISTEN_FDNAMES of the handed off listeners.
+	listenerNameHTTP  = "http"
+	listenerNameAdmin = "admin"
+)
+
+// ErrListenerNotInheritable is the error returned when the listener can not be passed to a child process.
+var ErrListenerNotInheritable = errors.New("listener does not expose its file descriptor")
+
+// inheritedListeners returns the listeners passed by systemd socket activation
+// or by a parent process during a graceful restart, or nil if there are none.
+//
+// The descriptor named "admin" in LISTEN_FDNAMES is returned as the admin listener
+// and the first other one as the listener of the server; the rest are closed.
+// The LISTEN_* variables are removed from the environment so they are not inherited by child processes.
+func inheritedListeners() (ln, admin net.Listener, err error) {
+	n, err := listenFDs(os.Getenv, os.Getpid())
+	names := strings.Split(os.Getenv(envListenFDNames), ":")
+
+	_ = os.Unsetenv(envListenFDs)
+	_ = os.Unsetenv(envListenPID)
+	_ = os.Unsetenv(envListenFDNames)
+
+	if err != nil || n == 0 {
+		return nil, nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			closeListeners(ln, admin)
+			return nil, nil, err
+		}
+
+		switch {
+		case admin == nil && i < len(names) && names[i] == listenerNameAdmin:
+			admin = l
+		case ln == nil:
+			ln = l
+		default:
+			l.Close()
+		}
+	}
+
+	return ln, admin, nil
+}
+
+// closeListeners closes the non-nil listeners.
+func closeListeners(lns ...net.Listener) {
+	for _, ln := range lns {
+		if ln != nil {
+			_ = ln.Close()
+		}
+	}
+}
+
+// listenFDs returns the number of file descriptors passed to the process with the given PID,
+// read from the environment with getenv. As with sd_listen_fds(3), the descriptors are meant
+// for the process only if LISTEN_PID is its PID. Otherwise, the variables are stray ones
+// inherited from an ancestor and zero is returned.
+func listenFDs(getenv func(string) string, pid int) (int, error) {
+	fds := getenv(envListenFDs)
+	if fds == "" || getenv(envListenPID) != strconv.Itoa(pid) {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("httpserver: invalid %s=%q", envListenFDs, fds)
+	}
+
+	return n, nil
+}
+
+// watchRestart re-executes the binary when one of the restart signals is received.
+// The child inherits the listeners and the drain of the current process is started by calling stop.
+// A failed restart is logged and the server keeps running.
+func (s *Server) watchRestart(ctx context.Context, ln, admin net.Listener, stop context.CancelFunc) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, s.restartSignals...)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sig:
+			if err := restart(ln, admin); err != nil {
+				s.logf("httpserver: restart: %v", err)
+				continue
+			}
+
+			stop()
+
+			return nil
+		}
+	}
+}
+
+// restart starts a new instance of the running binary that inherits ln as file descriptor 3
+// and the admin listener, if not nil, as file descriptor 4.
+//
+// The PID of the child is not known before it starts, so the binary is run through a shell
+// setting LISTEN_PID to its own PID before replacing itself with the binary, which keeps the PID.
+func restart(ln, admin net.Listener) error {
+	files, err := listenerFiles(ln, admin)
+	if err != nil {
+		return err
+	}
+
+	defer closeFiles(files)
+
+	names := []string{listenerNameHTTP, listenerNameAdmin}[:len(files)]
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"-c", `LISTEN_PID=$$ exec "$0" "$@"`, exe}, os.Args[1:]...)
+
+	cmd := exec.Command("/bin/sh", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(listenEnvFiltered(os.Environ()),
+		envListenFDs+"="+strconv.Itoa(len(files)),
+		envListenFDNames+"="+strings.Join(names, ":"),
+	)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
+
+// listenerFiles returns the duplicated file descriptors of the non-nil listeners.
+func listenerFiles(lns ...net.Listener) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(lns))
+
+	for _, ln := range lns {
+		if ln == nil {
+			continue
+		}
+
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, ErrListenerNotInheritable
+		}
+
+		// The socket file must survive the listener being closed by the draining parent.
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+
+		f, err := filer.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// closeFiles closes files.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// listenEnvFiltered returns env without the LISTEN_* variables.
+func listenEnvFiltered(env []string) []string {
+	filtered := make([]string, 0, len(env))
+
+	for _, kv := range env {
+		if strings.HasPrefix(kv, envListenFDs+"=") ||
+			strings.HasPrefix(kv, envListenPID+"=") ||
+			strings.HasPrefix(kv, envListenFDNames+"=") {
+			continue
+		}
+
+		filtered = append(filtered, kv)
+	}
+
+	return filtered
+}
@@ -0,0 +1,250 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envTestHelper makes the test binary run as a helper process, see Test_inheritedListeners.
+const envTestHelper = "NIX_TEST_HELPER"
+
+func Test_listenFDs(t *testing.T) {
+	t.Parallel()
+
+	const pid = 100
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected int
+		err      bool
+	}{
+		{name: "no variables", env: map[string]string{}},
+		{name: "systemd", env: map[string]string{envListenFDs: "2", envListenPID: "100"}, expected: 2},
+		{name: "other process", env: map[string]string{envListenFDs: "1", envListenPID: "101"}},
+		{name: "stray variables", env: map[string]string{envListenFDs: "1"}},
+		{name: "invalid count", env: map[string]string{envListenFDs: "zero", envListenPID: "100"}, err: true},
+		{name: "no descriptors", env: map[string]string{envListenFDs: "0", envListenPID: "100"}, err: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, err := listenFDs(func(key string) string { return tc.env[key] }, pid)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, n)
+		})
+	}
+}
+
+func Test_listenEnvFiltered(t *testing.T) {
+	env := []string{
+		"PATH=/bin",
+		"LISTEN_FDS=1",
+		"LISTEN_PID=7",
+		"LISTEN_FDNAMES=http",
+		"LISTEN_FDS_EXTRA=kept",
+	}
+
+	assert.Equal(t, []string{"PATH=/bin", "LISTEN_FDS_EXTRA=kept"}, listenEnvFiltered(env))
+}
+
+// Test_inheritedListeners runs the test binary as a child process that inherits listeners from fd 3.
+func Test_inheritedListeners(t *testing.T) {
+	if os.Getenv(envTestHelper) == "inherit" {
+		ln, admin, err := inheritedListeners()
+
+		switch {
+		case err != nil:
+			fmt.Println("error", err)
+		case ln == nil:
+			fmt.Println("none")
+		case admin == nil:
+			fmt.Println("addr", ln.Addr())
+		default:
+			fmt.Println("addr", ln.Addr(), "admin", admin.Addr())
+		}
+
+		fmt.Printf("env %q\n", os.Getenv(envListenFDs)+os.Getenv(envListenPID))
+		os.Exit(0)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	adminLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer adminLn.Close()
+
+	files, err := listenerFiles(ln, adminLn)
+	require.NoError(t, err)
+	defer closeFiles(files)
+
+	tests := []struct {
+		name  string
+		env   []string
+		files int
+		// otherPID sets LISTEN_PID to the PID of the test instead of the child.
+		otherPID bool
+		expected string
+	}{
+		{
+			name:     "one listener",
+			env:      []string{envListenFDs + "=1"},
+			expected: "addr " + ln.Addr().String(),
+		},
+		{
+			name:     "admin",
+			env:      []string{envListenFDs + "=2", envListenFDNames + "=http:admin"},
+			files:    2,
+			expected: "addr " + ln.Addr().String() + " admin " + adminLn.Addr().String(),
+		},
+		{
+			name:     "admin first",
+			env:      []string{envListenFDs + "=2", envListenFDNames + "=admin:http"},
+			files:    2,
+			expected: "addr " + adminLn.Addr().String() + " admin " + ln.Addr().String(),
+		},
+		{
+			name:     "other process",
+			env:      []string{envListenFDs + "=1"},
+			otherPID: true,
+			expected: "none",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			// As restart does, the shell sets LISTEN_PID to its own PID, which exec keeps.
+			script := `LISTEN_PID=$$ exec "$0" "$@"`
+			if tc.otherPID {
+				script = `LISTEN_PID=` + strconv.Itoa(os.Getpid()) + ` exec "$0" "$@"`
+			}
+
+			cmd := exec.Command("/bin/sh", "-c", script, os.Args[0], "-test.run=^Test_inheritedListeners$")
+			cmd.Env = append(listenEnvFiltered(os.Environ()), append(tc.env, envTestHelper+"=inherit")...)
+			cmd.ExtraFiles = files[:max(tc.files, 1)]
+
+			out, err := cmd.Output()
+			require.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			require.Len(t, lines, 2, string(out))
+			assert.Equal(t, tc.expected, lines[0])
+			assert.Equal(t, `env ""`, lines[1], "variables are removed")
+		})
+	}
+}
+
+// localURL returns the URL of the loopback interface on the port of addr.
+func localURL(t *testing.T, addr, path string) string {
+	t.Helper()
+
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	return "http://" + net.JoinHostPort("127.0.0.1", port) + path
+}
+
+// runRestartHelper runs a server with an admin server that restarts on SIGHUP.
+// Once both are served, it prints "ready <pid> <addr> <admin addr>".
+func runRestartHelper(t *testing.T) {
+	// Until the server watches it, SIGHUP must not kill the process.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGHUP)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	pid := strconv.Itoa(os.Getpid())
+	srv := New(ctx, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, pid)
+	}), Port("0"), Admin("127.0.0.1:0"), GracefulRestart())
+
+	go func() {
+		for srv.Addr() == nil || srv.AdminAddr() == nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		get(t, http.DefaultClient, localURL(t, srv.Addr().String(), "/"))
+		get(t, http.DefaultClient, localURL(t, srv.AdminAddr().String(), "/debug/pprof/"))
+		fmt.Println("ready", pid, srv.Addr(), srv.AdminAddr())
+	}()
+
+	if err := srv.Run(ctx); err != nil {
+		fmt.Println("error", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func Test_Server_GracefulRestart(t *testing.T) {
+	if os.Getenv(envTestHelper) == "restart" {
+		runRestartHelper(t)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_Server_GracefulRestart$")
+	cmd.Env = append(listenEnvFiltered(os.Environ()), envTestHelper+"=restart")
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	// The child inherits the pipe, so both processes report on it.
+	lines := bufio.NewScanner(out)
+	ready := func() []string {
+		for lines.Scan() {
+			if fields := strings.Fields(lines.Text()); len(fields) == 4 && fields[0] == "ready" {
+				return fields[1:]
+			}
+		}
+
+		t.Fatalf("process exited before it was ready: %v", lines.Err())
+
+		return nil
+	}
+
+	parent := ready()
+	require.NoError(t, cmd.Process.Signal(syscall.SIGHUP))
+
+	child := ready()
+	childPID, err := strconv.Atoi(child[0])
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = syscall.Kill(childPID, syscall.SIGTERM) })
+
+	assert.NotEqual(t, parent[0], child[0])
+	assert.Equal(t, parent[1:], child[1:], "the child serves on the listeners of the parent")
+
+	// The parent drains and exits, leaving the listeners to the child.
+	require.NoError(t, cmd.Wait())
+
+	assert.Equal(t, child[0], get(t, http.DefaultClient, localURL(t, child[1], "/")))
+	get(t, http.DefaultClient, localURL(t, child[2], "/debug/pprof/"))
+}
@@ -0,0 +1,114 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const defaultAdminReadHeaderTimeout = 5 * time.Second
+
+// adminOptions holds the settings of the admin server.
+type adminOptions struct {
+	addr  string
+	level *slog.LevelVar
+}
+
+// newAdminServer creates the admin server serving pprof, expvar, build info, the log level and metrics.
+// It has no write timeout, since profiles take as long as the client asks.
+func (s *Server) newAdminServer() *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/buildinfo", serveBuildInfo)
+
+	if s.admin.level != nil {
+		mux.Handle("/debug/loglevel", levelHandler(s.admin.level))
+	}
+
+	if s.metrics != nil {
+		mux.Handle("/metrics", s.metrics)
+	}
+
+	return &http.Server{
+		Addr:              s.admin.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: defaultAdminReadHeaderTimeout,
+		ErrorLog:          s.server.ErrorLog,
+	}
+}
+
+// buildInfo is the body of the build info endpoint.
+type buildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"`
+}
+
+func serveBuildInfo(w http.ResponseWriter, _ *http.Request) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info is not available", http.StatusNotFound)
+		return
+	}
+
+	info := buildInfo{
+		GoVersion: bi.GoVersion,
+		Path:      bi.Path,
+		Version:   bi.Main.Version,
+		Settings:  make(map[string]string, len(bi.Settings)),
+	}
+
+	for _, s := range bi.Settings {
+		info.Settings[s.Key] = s.Value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// levelBody is the body of the log level endpoint.
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// levelHandler reports the level on GET and changes it on PUT, e.g. with {"level":"debug"}.
+func levelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body levelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(strings.TrimSpace(body.Level))); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			level.Set(l)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelBody{Level: level.Level().String()})
+	})
+}
@@ -0,0 +1,96 @@
+package httpserver
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_levelHandler(t *testing.T) {
+	type testCase struct {
+		name          string
+		method        string
+		body          string
+		expectedCode  int
+		expectedLevel slog.Level
+	}
+
+	testCases := []testCase{
+		{
+			name:          "get level",
+			method:        http.MethodGet,
+			expectedCode:  http.StatusOK,
+			expectedLevel: slog.LevelInfo,
+		},
+		{
+			name:          "set level",
+			method:        http.MethodPut,
+			body:          `{"level":"debug"}`,
+			expectedCode:  http.StatusOK,
+			expectedLevel: slog.LevelDebug,
+		},
+		{
+			name:          "unknown level",
+			method:        http.MethodPut,
+			body:          `{"level":"loud"}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedLevel: slog.LevelInfo,
+		},
+		{
+			name:          "method not allowed",
+			method:        http.MethodDelete,
+			expectedCode:  http.StatusMethodNotAllowed,
+			expectedLevel: slog.LevelInfo,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			level := new(slog.LevelVar)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/debug/loglevel", strings.NewReader(tc.body))
+			levelHandler(level).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedLevel, level.Level())
+		})
+	}
+}
+
+func Test_Server_Admin(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := New(context.Background(), helloHandler,
+		Listener(ln),
+		Admin("127.0.0.1:0"),
+		Metrics(metrics.NewRegistry()),
+	)
+	assert.Nil(t, srv.AdminAddr())
+
+	stop := startServer(t, srv)
+	require.Eventually(t, func() bool { return srv.AdminAddr() != nil }, time.Second, time.Millisecond)
+
+	admin := "http://" + srv.AdminAddr().String()
+	assert.NotEqual(t, srv.Addr().String(), srv.AdminAddr().String())
+
+	get(t, http.DefaultClient, admin+"/metrics")
+	assert.Contains(t, get(t, http.DefaultClient, admin+"/debug/pprof/"), "goroutine")
+	assert.Equal(t, "hello", get(t, http.DefaultClient, "http://"+srv.Addr().String()+"/metrics"),
+		"metrics are not served on the main port")
+
+	require.NoError(t, stop())
+}
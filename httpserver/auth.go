@@ -0,0 +1,200 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/romankravchuk/nix/jwt"
+)
+
+const defaultAuthRealm = "api"
+
+// ErrNoPayload is the error returned when the request context holds no token payload.
+var ErrNoPayload = errors.New("no token payload in context")
+
+type authPayloadKey struct{}
+
+// authPayload wraps the token payload in the request context, so a null payload
+// still marks the request as authenticated.
+type authPayload struct {
+	value interface{}
+}
+
+// AuthConfig configures the Authenticate middleware.
+type AuthConfig struct {
+	// Key is the public key the tokens are validated with.
+	Key *rsa.PublicKey
+	// Cookie is the name of a cookie holding the token, used when there is no Authorization header.
+	Cookie string
+	// Realm is the realm reported in the WWW-Authenticate header, "api" by default.
+	Realm string
+}
+
+// Authenticate returns a middleware that validates the token of the Authorization: Bearer header,
+// or of the configured cookie, with jwt.ValidateToken. The decoded payload is stored in the
+// request context, see PayloadFromContext. Requests without a valid token get 401 Unauthorized
+// with a WWW-Authenticate header.
+func Authenticate(cfg AuthConfig) Middleware {
+	if cfg.Realm == "" {
+		cfg.Realm = defaultAuthRealm
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" && cfg.Cookie != "" {
+				if c, err := r.Cookie(cfg.Cookie); err == nil {
+					token = c.Value
+				}
+			}
+
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", cfg.Realm))
+				_ = WriteError(w, NewError(http.StatusUnauthorized, "missing token"))
+				return
+			}
+
+			payload, err := jwt.ValidateToken(token, cfg.Key)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", cfg.Realm))
+				_ = WriteError(w, NewError(http.StatusUnauthorized, "invalid token"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authPayloadKey{}, authPayload{value: payload})))
+		})
+	}
+}
+
+// PayloadFromContext returns the token payload stored by Authenticate and reports whether
+// the request was authenticated. The payload is decoded from JSON, so objects are map[string]interface{}
+// and a null payload is nil.
+func PayloadFromContext(ctx context.Context) (interface{}, bool) {
+	payload, ok := ctx.Value(authPayloadKey{}).(authPayload)
+	return payload.value, ok
+}
+
+// PayloadAs returns the token payload stored by Authenticate converted to T through JSON.
+func PayloadAs[T any](ctx context.Context) (T, error) {
+	var dst T
+
+	payload, ok := PayloadFromContext(ctx)
+	if !ok {
+		return dst, ErrNoPayload
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return dst, err
+	}
+
+	err = json.Unmarshal(data, &dst)
+
+	return dst, err
+}
+
+// ClaimString returns the string field of the token payload, e.g. "sub".
+func ClaimString(ctx context.Context, field string) (string, bool) {
+	payload, _ := PayloadFromContext(ctx)
+
+	m, ok := payload.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	s, ok := m[field].(string)
+
+	return s, ok
+}
+
+// Require returns a middleware that lets through the requests whose token payload satisfies allow.
+// Others get 403 Forbidden, or 401 Unauthorized with a WWW-Authenticate header if Authenticate did not run.
+func Require(allow func(payload interface{}) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload, ok := PayloadFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				_ = WriteError(w, NewError(http.StatusUnauthorized, "missing token"))
+				return
+			}
+
+			if !allow(payload) {
+				_ = WriteError(w, NewError(http.StatusForbidden, "insufficient permissions"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope returns a middleware that lets through the tokens holding all the scopes
+// in the "scope" field of the payload, either a space separated string or a list.
+func RequireScope(scopes ...string) Middleware {
+	return Require(func(payload interface{}) bool {
+		return containsAll(payloadList(payload, "scope"), scopes)
+	})
+}
+
+// RequireRole returns a middleware that lets through the tokens holding one of the roles
+// in the "role" or "roles" field of the payload.
+func RequireRole(roles ...string) Middleware {
+	return Require(func(payload interface{}) bool {
+		held := append(payloadList(payload, "role"), payloadList(payload, "roles")...)
+
+		for _, role := range roles {
+			if containsAll(held, []string{role}) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// payloadList returns the field of the payload as a list of strings.
+// A string value is split on spaces, as OAuth 2.0 does for scopes.
+func payloadList(payload interface{}, field string) []string {
+	m, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	switch v := m[field].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	default:
+		return nil
+	}
+}
+
+// containsAll reports whether held contains every item of wanted.
+func containsAll(held, wanted []string) bool {
+	set := make(map[string]struct{}, len(held))
+	for _, h := range held {
+		set[h] = struct{}{}
+	}
+
+	for _, w := range wanted {
+		if _, ok := set[w]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
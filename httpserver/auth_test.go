@@ -0,0 +1,176 @@
+package httpserver
+
+import (
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/romankravchuk/nix/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRSAKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+	t.Helper()
+
+	pvPem, err := os.ReadFile("../jwt/testdata/private.pem")
+	require.NoError(t, err)
+	pubPem, err := os.ReadFile("../jwt/testdata/public.pem")
+	require.NoError(t, err)
+
+	pv, err := gojwt.ParseRSAPrivateKeyFromPEM(pvPem)
+	require.NoError(t, err)
+	pub, err := gojwt.ParseRSAPublicKeyFromPEM(pubPem)
+	require.NoError(t, err)
+
+	return pv, pub
+}
+
+func Test_Authenticate(t *testing.T) {
+	type testCase struct {
+		name           string
+		authorization  string
+		cookie         string
+		guard          Middleware
+		expectedCode   int
+		expectedHeader string
+	}
+
+	pv, pub := loadRSAKeys(t)
+
+	details, err := jwt.CreateToken(map[string]interface{}{
+		"sub":   "42",
+		"scope": "orders:read orders:write",
+		"roles": []string{"admin"},
+	}, time.Hour, pv)
+	require.NoError(t, err)
+
+	testCases := []testCase{
+		{
+			name:          "bearer token",
+			authorization: "Bearer " + details.Token,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "cookie",
+			cookie:       details.Token,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "missing token",
+			expectedCode:   http.StatusUnauthorized,
+			expectedHeader: `Bearer realm="api"`,
+		},
+		{
+			name:           "invalid token",
+			authorization:  "Bearer " + details.Token + "x",
+			expectedCode:   http.StatusUnauthorized,
+			expectedHeader: `Bearer realm="api", error="invalid_token"`,
+		},
+		{
+			name:          "scope granted",
+			authorization: "Bearer " + details.Token,
+			guard:         RequireScope("orders:read", "orders:write"),
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "scope missing",
+			authorization: "Bearer " + details.Token,
+			guard:         RequireScope("orders:delete"),
+			expectedCode:  http.StatusForbidden,
+		},
+		{
+			name:          "role granted",
+			authorization: "Bearer " + details.Token,
+			guard:         RequireRole("support", "admin"),
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "role missing",
+			authorization: "Bearer " + details.Token,
+			guard:         RequireRole("support"),
+			expectedCode:  http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			chain := NewChain(Authenticate(AuthConfig{Key: pub, Cookie: "session"}))
+			if tc.guard != nil {
+				chain = chain.Append(tc.guard)
+			}
+
+			h := chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+				sub, ok := ClaimString(r.Context(), "sub")
+				assert.True(t, ok)
+				assert.Equal(t, "42", sub)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tc.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedHeader, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func Test_PayloadAs(t *testing.T) {
+	type claims struct {
+		Sub   string   `json:"sub"`
+		Roles []string `json:"roles"`
+	}
+
+	pv, pub := loadRSAKeys(t)
+
+	details, err := jwt.CreateToken(claims{Sub: "42", Roles: []string{"admin"}}, time.Hour, pv)
+	require.NoError(t, err)
+
+	var got claims
+	h := Authenticate(AuthConfig{Key: pub})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, err = PayloadAs[claims](r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+details.Token)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, claims{Sub: "42", Roles: []string{"admin"}}, got)
+}
+
+func Test_Require(t *testing.T) {
+	pv, pub := loadRSAKeys(t)
+
+	details, err := jwt.CreateToken(nil, time.Hour, pv)
+	require.NoError(t, err)
+
+	guard := Require(func(payload interface{}) bool { return payload == nil })
+
+	rec := httptest.NewRecorder()
+	guard(helloHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "not authenticated")
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+details.Token)
+
+	rec = httptest.NewRecorder()
+	NewChain(Authenticate(AuthConfig{Key: pub}), guard).Then(helloHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, "a null payload is authenticated")
+}
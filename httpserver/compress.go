@@ -0,0 +1,337 @@
+package httpserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultCompressMinSize = 1024
+
+// incompressibleTypes are the content types that are already compressed.
+var incompressibleTypes = map[string]struct{}{
+	"application/gzip":             {},
+	"application/x-gzip":           {},
+	"application/zip":              {},
+	"application/zstd":             {},
+	"application/x-7z-compressed":  {},
+	"application/x-rar-compressed": {},
+	"application/pdf":              {},
+	"application/octet-stream":     {},
+	"font/woff":                    {},
+	"font/woff2":                   {},
+}
+
+// CompressConfig configures the Compress middleware.
+type CompressConfig struct {
+	// Level is the compression level, gzip.DefaultCompression by default.
+	Level int
+	// MinSize is the size under which responses are sent uncompressed, 1KiB by default.
+	// Streamed responses are compressed from the first flush regardless of their size.
+	MinSize int
+}
+
+// Compress returns a middleware that compresses responses with gzip or deflate,
+// as negotiated with the Accept-Encoding header of the request. Small responses, responses
+// that already have a Content-Encoding, partial responses and already compressed content types
+// such as images are sent as is. A strong ETag of a compressed response is made weak, since
+// the encoded bytes differ from the original ones. Flushing the response flushes the compressed
+// stream, so it is safe for server-sent events, and upgraded connections such as websockets
+// are not touched. It panics if the compression level is invalid.
+func Compress(cfg CompressConfig) Middleware {
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+
+	if _, err := gzip.NewWriterLevel(io.Discard, cfg.Level); err != nil {
+		panic(fmt.Sprintf("httpserver: invalid compression level %d", cfg.Level))
+	}
+
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = defaultCompressMinSize
+	}
+
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+		"deflate": {New: func() any {
+			w, _ := zlib.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				minSize:        cfg.MinSize,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressor is implemented by *gzip.Writer and *zlib.Writer.
+// The deflate content coding is the zlib format, see RFC 9110, section 8.4.1.2.
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// compressWriter buffers the beginning of the response until it knows whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+
+	status   int
+	buf      []byte
+	decided  bool
+	hijacked bool
+	cw       compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+
+	if code < http.StatusOK && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+
+	if !bodyAllowed(code) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		if len(w.buf)+len(b) < w.minSize {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+
+		w.buf = append(w.buf, b...)
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the header, compressed if allowed and the content type is compressible,
+// and the buffered beginning of the body.
+func (w *compressWriter) decide(allowed bool) error {
+	w.decided = true
+
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	// Ranges refer to the bytes of the original representation, so partial responses are sent as is.
+	partial := w.status == http.StatusPartialContent || h.Get("Content-Range") != ""
+
+	if allowed && !partial && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", w.encoding)
+
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.cw = w.pool.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+
+	w.buf = nil
+
+	return err
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close sends the rest of the response and returns the compressor to the pool.
+func (w *compressWriter) close() {
+	if w.hijacked {
+		return
+	}
+
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+
+		if len(w.buf) > 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+
+		_ = w.decide(false)
+	}
+
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+// bodyAllowed reports whether a response with the status may have a body.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK
+}
+
+// compressible reports whether the content type is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := incompressibleTypes[mediaType]; ok {
+		return false
+	}
+
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return false
+	default:
+		return true
+	}
+}
+
+// negotiateEncoding returns the preferred encoding among gzip and deflate accepted by
+// the Accept-Encoding header, or an empty string if none is.
+func negotiateEncoding(acceptEncoding string) string {
+	var (
+		best  string
+		bestQ float64
+		// anyQ is the quality of "*", applied to the encodings that are not listed.
+		anyQ   = -1.0
+		listed = make(map[string]float64, 2)
+	)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		switch coding {
+		case "gzip", "x-gzip":
+			listed["gzip"] = q
+		case "deflate":
+			listed["deflate"] = q
+		case "*":
+			anyQ = q
+		}
+	}
+
+	for _, enc := range []string{"gzip", "deflate"} {
+		q, ok := listed[enc]
+		if !ok {
+			q = anyQ
+		}
+
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
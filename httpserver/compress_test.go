@@ -0,0 +1,220 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_negotiateEncoding(t *testing.T) {
+	testCases := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"deflate":                 "deflate",
+		"gzip, deflate, br":       "gzip",
+		"gzip;q=0.5, deflate":     "deflate",
+		"gzip;q=0, *":             "deflate",
+		"*;q=0":                   "",
+		"br, *":                   "gzip",
+		"GZIP;q=0.8, deflate;q=1": "deflate",
+	}
+
+	for header, expected := range testCases {
+		assert.Equal(t, expected, negotiateEncoding(header), header)
+	}
+}
+
+func Test_Compress(t *testing.T) {
+	type testCase struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		body             string
+		expectedEncoding string
+	}
+
+	large := strings.Repeat(`{"id":1,"name":"nix"}`, 100)
+
+	testCases := []testCase{
+		{
+			name:             "gzip",
+			acceptEncoding:   "gzip",
+			contentType:      "application/json",
+			body:             large,
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "deflate",
+			acceptEncoding:   "deflate",
+			contentType:      "application/json",
+			body:             large,
+			expectedEncoding: "deflate",
+		},
+		{
+			name:           "not accepted",
+			acceptEncoding: "",
+			contentType:    "application/json",
+			body:           large,
+		},
+		{
+			name:           "small body",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           `{"id":1}`,
+		},
+		{
+			name:           "already compressed type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           large,
+		},
+		{
+			name:             "sniffed type",
+			acceptEncoding:   "gzip",
+			body:             large,
+			expectedEncoding: "gzip",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := Compress(CompressConfig{})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+
+				// Written in two parts to cross the minimum size.
+				_, _ = io.WriteString(w, tc.body[:len(tc.body)/2])
+				_, _ = io.WriteString(w, tc.body[len(tc.body)/2:])
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, []string{"Accept-Encoding"}, rec.Header().Values("Vary"))
+
+			var body io.Reader = rec.Body
+			switch tc.expectedEncoding {
+			case "gzip":
+				gz, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				body = gz
+			case "deflate":
+				zr, err := zlib.NewReader(rec.Body)
+				require.NoError(t, err)
+				body = zr
+			default:
+				assert.Equal(t, len(tc.body), rec.Body.Len())
+			}
+
+			decoded, err := io.ReadAll(body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(decoded))
+		})
+	}
+}
+
+func Test_Compress_Flush(t *testing.T) {
+	flushed := make(chan struct{})
+	proceed := make(chan struct{})
+
+	h := Compress(CompressConfig{})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: first\n\n")
+		assert.NoError(t, http.NewResponseController(w).Flush())
+
+		close(flushed)
+		<-proceed
+
+		_, _ = io.WriteString(w, "data: second\n\n")
+	}))
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	<-flushed
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+
+	first := make([]byte, len("data: first\n\n"))
+	_, err = io.ReadFull(gz, first)
+	require.NoError(t, err)
+	assert.Equal(t, "data: first\n\n", string(first))
+
+	close(proceed)
+
+	rest, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "data: second\n\n", string(rest))
+}
+
+func Test_Compress_ranges(t *testing.T) {
+	content := strings.Repeat("compressible content ", 200)
+
+	static, err := Static(fstest.MapFS{"app.txt": {Data: []byte(content)}}, StaticConfig{})
+	require.NoError(t, err)
+
+	h := Compress(CompressConfig{})(static)
+
+	do := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/app.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := do(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Accept-Ranges"))
+
+	etag := rec.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), "ETag of the encoded variant is weak: %s", etag)
+
+	rec = do(map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = do(map[string]string{"Range": "bytes=0-9"})
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "bytes 0-9/"+strconv.Itoa(len(content)), rec.Header().Get("Content-Range"))
+	assert.Equal(t, content[:10], rec.Body.String())
+}
+
+func Test_Compress_invalidLevel(t *testing.T) {
+	assert.Panics(t, func() { Compress(CompressConfig{Level: 42}) })
+}
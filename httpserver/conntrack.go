@@ -0,0 +1,93 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnStats reports the number of open connections of the server by state.
+type ConnStats struct {
+	// Active is the number of connections reading or serving a request.
+	Active int
+	// Idle is the number of keep-alive connections waiting for the next request.
+	Idle int
+	// Hijacked is the number of hijacked connections tracked with TrackHijacked.
+	Hijacked int
+}
+
+// connTracker keeps the state of the connections of an http.Server, see http.Server.ConnState.
+// Hijacked and closed connections are no longer tracked.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns: make(map[net.Conn]http.ConnState),
+	}
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew, http.StateActive, http.StateIdle:
+		t.conns[c] = state
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	}
+}
+
+func (t *connTracker) stats() ConnStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stats ConnStats
+
+	for _, state := range t.conns {
+		if state == http.StateIdle {
+			stats.Idle++
+		} else {
+			stats.Active++
+		}
+	}
+
+	return stats
+}
+
+// closeIdle closes the idle connections and returns how many were closed.
+func (t *connTracker) closeIdle() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var n int
+
+	for c, state := range t.conns {
+		if state != http.StateIdle {
+			continue
+		}
+
+		_ = c.Close()
+		delete(t.conns, c)
+		n++
+	}
+
+	return n
+}
+
+// ConnStats returns the number of active, idle and hijacked connections of the server.
+func (s *Server) ConnStats() ConnStats {
+	stats := s.conns.stats()
+	stats.Hijacked = s.hijacked.count()
+
+	return stats
+}
+
+// CloseIdleConns closes the idle keep-alive connections of the server and returns how many were closed.
+// It is also done when the graceful shutdown starts.
+func (s *Server) CloseIdleConns() int {
+	return s.conns.closeIdle()
+}
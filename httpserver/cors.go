@@ -0,0 +1,240 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the CORS middleware.
+type CORSConfig struct {
+	// AllowedOrigins lists the allowed origins, e.g. "https://example.com".
+	// "*" allows any origin and "https://*.example.com" allows any subdomain of example.com.
+	AllowedOrigins []string
+	// AllowOriginFunc is called for the origins not matched by AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods lists the allowed methods, GET, HEAD and POST by default.
+	AllowedMethods []string
+	// AllowedHeaders lists the allowed request headers, "*" allows any header.
+	// Accept, Accept-Language, Content-Language and Content-Type are allowed by default.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers the browser exposes to the page.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies and HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is how long the browser may cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// cors is the compiled CORSConfig.
+type cors struct {
+	cfg CORSConfig
+
+	allOrigins bool
+	origins    map[string]struct{}
+	// wildcards holds the prefix and suffix around "*" of the wildcard origins.
+	wildcards [][2]string
+
+	methods    map[string]struct{}
+	allHeaders bool
+	headers    map[string]struct{}
+
+	allowMethods  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// CORS returns a middleware that implements Cross-Origin Resource Sharing.
+// Preflight requests are answered with 204 No Content and are not passed to the next handler.
+// Responses vary by Origin, and preflight responses also by the requested method and headers.
+func CORS(cfg CORSConfig) Middleware {
+	c := newCORS(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newCORS(cfg CORSConfig) *cors {
+	c := &cors{
+		cfg:     cfg,
+		origins: make(map[string]struct{}),
+		methods: make(map[string]struct{}),
+		headers: make(map[string]struct{}),
+	}
+
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(o)
+
+		switch {
+		case o == "*":
+			c.allOrigins = true
+		case strings.Contains(o, "*"):
+			prefix, suffix, _ := strings.Cut(o, "*")
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		default:
+			c.origins[o] = struct{}{}
+		}
+	}
+
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	if len(cfg.AllowedMethods) > 0 {
+		methods = make([]string, len(cfg.AllowedMethods))
+		for i, m := range cfg.AllowedMethods {
+			methods[i] = strings.ToUpper(m)
+		}
+	}
+
+	for _, m := range methods {
+		c.methods[m] = struct{}{}
+	}
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+	}
+
+	for _, h := range headers {
+		if h == "*" {
+			c.allHeaders = true
+			continue
+		}
+
+		c.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	c.allowMethods = strings.Join(methods, ", ")
+	c.exposeHeaders = strings.Join(cfg.ExposedHeaders, ", ")
+
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return c
+}
+
+// preflight answers a preflight request. Disallowed requests get no CORS headers,
+// so the browser blocks the actual request.
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowOrigin(origin) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if _, ok := c.methods[method]; !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowHeaders(requested) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// actual adds the CORS headers to the response of an actual request.
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowOrigin(origin) {
+		return
+	}
+
+	c.setOrigin(h, origin)
+
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+}
+
+// setOrigin sets the allowed origin and credentials headers. The wildcard origin
+// can not be used with credentials, so the origin is reflected instead.
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.allOrigins && !c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allOrigins {
+		return true
+	}
+
+	o := strings.ToLower(origin)
+	if _, ok := c.origins[o]; ok {
+		return true
+	}
+
+	for _, w := range c.wildcards {
+		if len(o) > len(w[0])+len(w[1]) && strings.HasPrefix(o, w[0]) && strings.HasSuffix(o, w[1]) {
+			return true
+		}
+	}
+
+	return c.cfg.AllowOriginFunc != nil && c.cfg.AllowOriginFunc(origin)
+}
+
+func (c *cors) allowHeaders(requested []string) bool {
+	if c.allHeaders {
+		return true
+	}
+
+	for _, h := range requested {
+		if _, ok := c.headers[h]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseHeaderList splits a comma separated list of header names into canonical keys.
+func parseHeaderList(list string) []string {
+	var headers []string
+
+	for _, h := range strings.Split(list, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,201 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CORS(t *testing.T) {
+	type testCase struct {
+		name           string
+		cfg            CORSConfig
+		method         string
+		headers        map[string]string
+		expectedCode   int
+		expectedHeader map[string]string
+		expectedVary   []string
+	}
+
+	preflight := func(origin, method, headers string) map[string]string {
+		return map[string]string{
+			"Origin":                         origin,
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:         "no origin",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "exact origin",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}, ExposedHeaders: []string{"X-Total"}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":   "https://example.com",
+				"Access-Control-Expose-Headers": "X-Total",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "disallowed origin",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://evil.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "wildcard subdomain",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://*.example.com"}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://app.example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "https://app.example.com",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "wildcard does not match the apex",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://*.example.com"}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name: "predicate",
+			cfg: CORSConfig{AllowOriginFunc: func(origin string) bool {
+				return strings.HasSuffix(origin, ".internal")
+			}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "http://dash.internal"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "http://dash.internal",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "any origin with credentials is reflected",
+			cfg:          CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:         "any origin",
+			cfg:          CORSConfig{AllowedOrigins: []string{"*"}},
+			method:       http.MethodGet,
+			headers:      map[string]string{"Origin": "https://example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name: "preflight",
+			cfg: CORSConfig{
+				AllowedOrigins: []string{"https://example.com"},
+				AllowedMethods: []string{"get", "put"},
+				AllowedHeaders: []string{"content-type", "X-Token"},
+				MaxAge:         10 * time.Minute,
+			},
+			method:       http.MethodOptions,
+			headers:      preflight("https://example.com", "PUT", "x-token, content-type"),
+			expectedCode: http.StatusNoContent,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "X-Token, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:         "preflight with disallowed method",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			method:       http.MethodOptions,
+			headers:      preflight("https://example.com", "DELETE", ""),
+			expectedCode: http.StatusNoContent,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:         "preflight with disallowed header",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			method:       http.MethodOptions,
+			headers:      preflight("https://example.com", "POST", "X-Token"),
+			expectedCode: http.StatusNoContent,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:         "plain options request is passed through",
+			cfg:          CORSConfig{AllowedOrigins: []string{"https://example.com"}},
+			method:       http.MethodOptions,
+			headers:      map[string]string{"Origin": "https://example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "https://example.com",
+			},
+			expectedVary: []string{"Origin"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, "/", nil)
+			for k, v := range tc.headers {
+				if v != "" {
+					req.Header.Set(k, v)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			CORS(tc.cfg)(helloHandler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			for k, v := range tc.expectedHeader {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+			assert.Equal(t, tc.expectedVary, rec.Header().Values("Vary"))
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package health
+
+import (
+	"context"
+
+	"github.com/romankravchuk/nix/postgres"
+	"github.com/romankravchuk/nix/redis"
+)
+
+// Postgres returns a checker that pings the connection pool of p.
+func Postgres(p *postgres.Postgres) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return p.Pool.Ping(ctx)
+	})
+}
+
+// Redis returns a checker that pings the client of r.
+func Redis(r *redis.Redis) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return r.Client.Ping(ctx).Err()
+	})
+}
@@ -0,0 +1,198 @@
+// Package health provides liveness and readiness endpoints with pluggable checks.
+//
+// Example Usage:
+//
+//	h := health.New(health.Timeout(time.Second))
+//	h.AddReadinessCheck("postgres", health.Postgres(pg))
+//	h.AddReadinessCheck("redis", health.Redis(rd))
+//
+//	srv := httpserver.New(ctx, handler, httpserver.Health(h))
+//
+// The server then answers /livez and /readyz, and marks itself as not ready
+// as soon as the graceful shutdown starts.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTimeout = 2 * time.Second
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown is the error reported by the readiness endpoint once the shutdown has started.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Checker checks the health of a single dependency.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as checkers.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the body of the liveness and readiness responses.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+// Health holds the liveness and readiness checks of a service.
+type Health struct {
+	timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  []check
+	readiness []check
+
+	shuttingDown atomic.Bool
+}
+
+// New creates a new Health without checks.
+func New(opts ...Option) *Health {
+	h := &Health{
+		timeout: defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// AddLivenessCheck registers a check reported by the liveness endpoint.
+// Liveness checks should only fail when the process must be restarted.
+func (h *Health) AddLivenessCheck(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.liveness = append(h.liveness, check{name: name, checker: c})
+}
+
+// AddReadinessCheck registers a check reported by the readiness endpoint.
+func (h *Health) AddReadinessCheck(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.readiness = append(h.readiness, check{name: name, checker: c})
+}
+
+// Shutdown makes the readiness endpoint fail, so load balancers stop sending new requests.
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// LivenessHandler returns the handler of the liveness endpoint.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.RLock()
+		checks := h.liveness
+		h.mu.RUnlock()
+
+		writeReport(w, h.run(r.Context(), checks))
+	})
+}
+
+// ReadinessHandler returns the handler of the readiness endpoint.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.shuttingDown.Load() {
+			writeReport(w, Report{
+				Status: StatusFail,
+				Checks: map[string]Result{
+					"shutdown": {Status: StatusFail, Duration: "0s", Error: ErrShuttingDown.Error()},
+				},
+			})
+			return
+		}
+
+		h.mu.RLock()
+		checks := h.readiness
+		h.mu.RUnlock()
+
+		writeReport(w, h.run(r.Context(), checks))
+	})
+}
+
+// run executes the checks concurrently, each one limited by the timeout.
+func (h *Health) run(ctx context.Context, checks []check) Report {
+	var (
+		report = Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+
+	for _, c := range checks {
+		c := c
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.checker.Check(ctx)
+			res := Result{Status: StatusOK, Duration: time.Since(start).String()}
+
+			if err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[c.name] = res
+			if err != nil {
+				report.Status = StatusFail
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// writeReport writes the report as JSON with 200 OK, or 503 Service Unavailable if it failed.
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(report)
+}
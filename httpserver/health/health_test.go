@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Health_Readiness(t *testing.T) {
+	type testCase struct {
+		name         string
+		checks       map[string]Checker
+		shutdown     bool
+		expectedCode int
+		failed       []string
+	}
+
+	ok := CheckerFunc(func(context.Context) error { return nil })
+	failing := CheckerFunc(func(context.Context) error { return errors.New("down") })
+	slow := CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	testCases := []testCase{
+		{
+			name:         "no checks",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "all checks pass",
+			checks:       map[string]Checker{"postgres": ok, "redis": ok},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "one check fails",
+			checks:       map[string]Checker{"postgres": ok, "redis": failing},
+			expectedCode: http.StatusServiceUnavailable,
+			failed:       []string{"redis"},
+		},
+		{
+			name:         "check times out",
+			checks:       map[string]Checker{"postgres": slow},
+			expectedCode: http.StatusServiceUnavailable,
+			failed:       []string{"postgres"},
+		},
+		{
+			name:         "shutting down",
+			checks:       map[string]Checker{"postgres": ok},
+			shutdown:     true,
+			expectedCode: http.StatusServiceUnavailable,
+			failed:       []string{"shutdown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := New(Timeout(10 * time.Millisecond))
+			for name, c := range tc.checks {
+				h.AddReadinessCheck(name, c)
+			}
+
+			if tc.shutdown {
+				h.Shutdown()
+			}
+
+			rec := httptest.NewRecorder()
+			h.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var report Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+
+			for _, name := range tc.failed {
+				assert.Equal(t, StatusFail, report.Checks[name].Status)
+				assert.NotEmpty(t, report.Checks[name].Error)
+			}
+		})
+	}
+}
+
+func Test_Health_Liveness(t *testing.T) {
+	h := New()
+	h.AddReadinessCheck("postgres", CheckerFunc(func(context.Context) error { return errors.New("down") }))
+	h.Shutdown()
+
+	rec := httptest.NewRecorder()
+	h.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
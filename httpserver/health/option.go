@@ -0,0 +1,12 @@
+package health
+
+import "time"
+
+type Option func(h *Health)
+
+// Timeout limits the duration of every single check.
+func Timeout(timeout time.Duration) Option {
+	return func(h *Health) {
+		h.timeout = timeout
+	}
+}
@@ -0,0 +1,127 @@
+package httpserver
+
+import (
+	"context"
+	"sync"
+)
+
+type hijackedKey struct{}
+
+// HijackedConn is a connection taken over from the server, such as a WebSocket,
+// that the graceful shutdown has to close, see TrackHijacked.
+type HijackedConn interface {
+	// Shutdown asks the peer to close the connection, e.g. with a WebSocket close frame.
+	// It must not wait for the peer.
+	Shutdown() error
+	// Close closes the connection immediately.
+	Close() error
+}
+
+// TrackHijacked registers the connection hijacked from the request with the server serving it.
+// The graceful shutdown calls Shutdown on the tracked connections and waits for them to be untracked
+// until the shutdown timeout, when the remaining ones are closed. The returned function untracks
+// the connection and must be called once it is closed. If the server is already shutting down,
+// Shutdown is called right away. Connections of requests not served by a Server are not tracked.
+func TrackHijacked(ctx context.Context, c HijackedConn) (untrack func()) {
+	t, ok := ctx.Value(hijackedKey{}).(*hijackTracker)
+	if !ok {
+		return func() {}
+	}
+
+	if t.add(c) {
+		_ = c.Shutdown()
+	}
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { t.remove(c) })
+	}
+}
+
+// hijackTracker keeps the hijacked connections of a server.
+type hijackTracker struct {
+	mu        sync.Mutex
+	conns     map[HijackedConn]struct{}
+	closing   bool
+	drained   chan struct{}
+	drainOnce sync.Once
+}
+
+func newHijackTracker() *hijackTracker {
+	return &hijackTracker{
+		conns:   make(map[HijackedConn]struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+// add tracks the connection and reports whether the shutdown has started.
+func (t *hijackTracker) add(c HijackedConn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.conns[c] = struct{}{}
+
+	return t.closing
+}
+
+func (t *hijackTracker) remove(c HijackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.conns, c)
+
+	if t.closing && len(t.conns) == 0 {
+		t.drainOnce.Do(func() { close(t.drained) })
+	}
+}
+
+func (t *hijackTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.conns)
+}
+
+// snapshot returns the tracked connections.
+func (t *hijackTracker) snapshot() []HijackedConn {
+	conns := make([]HijackedConn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+// shutdown asks the connections to close and waits for them until the context is done,
+// when the remaining connections are closed and the context error is returned.
+func (t *hijackTracker) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closing = true
+	conns := t.snapshot()
+
+	if len(conns) == 0 {
+		t.drainOnce.Do(func() { close(t.drained) })
+	}
+	t.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Shutdown()
+	}
+
+	select {
+	case <-t.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	conns = t.snapshot()
+	t.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+
+	return ctx.Err()
+}
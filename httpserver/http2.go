@@ -0,0 +1,23 @@
+package httpserver
+
+import "golang.org/x/net/http2"
+
+// http2Server returns the HTTP/2 settings of the server, creating them on first use.
+func (s *Server) http2Server() *http2.Server {
+	if s.http2 == nil {
+		s.http2 = &http2.Server{}
+	}
+
+	return s.http2
+}
+
+// configureHTTP2 applies the HTTP/2 settings to the server. It must be called after
+// the TLS config is set, since it adds "h2" to the negotiated protocols.
+// Configuring the server also makes the h2c connections receive GOAWAY on shutdown.
+func (s *Server) configureHTTP2() error {
+	if s.http2 == nil {
+		return nil
+	}
+
+	return http2.ConfigureServer(s.server, s.http2)
+}
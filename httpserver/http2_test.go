@@ -0,0 +1,96 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.WriteString(w, r.Proto)
+})
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return string(body)
+}
+
+func Test_Server_H2C(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := New(context.Background(), protoHandler,
+		Listener(ln),
+		H2C(),
+		HTTP2MaxConcurrentStreams(10),
+	)
+	stop := startServer(t, srv)
+
+	h2c := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	url := "http://" + srv.Addr().String()
+
+	assert.Equal(t, "HTTP/2.0", get(t, h2c, url))
+	assert.Equal(t, "HTTP/1.1", get(t, http.DefaultClient, url))
+
+	require.NoError(t, stop())
+}
+
+func Test_Server_TLS_HTTP2(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), 1)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := New(context.Background(), protoHandler,
+		Listener(ln),
+		TLS(certFile, keyFile),
+		TLSReloadInterval(0),
+		HTTP2MaxConcurrentStreams(10),
+		HTTP2MaxReadFrameSize(1<<20),
+	)
+	stop := startServer(t, srv)
+
+	certPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(certPEM))
+
+	tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	url := "https://" + srv.Addr().String()
+
+	h2 := &http.Client{Transport: &http2.Transport{TLSClientConfig: tlsConfig}}
+	assert.Equal(t, "HTTP/2.0", get(t, h2, url))
+
+	h1 := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	assert.Equal(t, "HTTP/1.1", get(t, h1, url))
+
+	require.NoError(t, stop())
+}
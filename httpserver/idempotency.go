@@ -0,0 +1,517 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/romankravchuk/nix/redis"
+)
+
+const (
+	// IdempotencyKeyHeader is the header carrying the idempotency key of a request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed by Idempotency.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	redisReserveAttempts    = 3
+
+	// idempotencyLockTTL bounds the reservation of a key in progress, so a key reserved
+	// by an instance that crashed before completing the request is released.
+	// The reservation is extended every idempotencyExtendInterval while the request is served.
+	idempotencyLockTTL        = 30 * time.Second
+	idempotencyExtendInterval = idempotencyLockTTL / 3
+	// maxIdempotentBodyBytes is the limit of the request body read to compute the fingerprint.
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// IdempotentResponse is a response captured by Idempotency.
+type IdempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// ErrIdempotencyKeyLost is the error returned when a reservation of an idempotency key
+// has expired or is held by another request.
+var ErrIdempotencyKeyLost = errors.New("idempotency key reservation lost")
+
+// IdempotencyRecord is the state of an idempotency key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string `json:"fingerprint"`
+	// Token identifies the reservation of the key, so only its holder can complete or release it.
+	Token string `json:"token"`
+	// Response is the captured response, or nil while the request is in progress.
+	Response *IdempotentResponse `json:"response,omitempty"`
+}
+
+// IdempotencyStore keeps the records of idempotency keys.
+// Implementations must be safe for concurrent use.
+//
+// A reservation is held with the token of the reserved record for a short time, 30 seconds
+// for the stores of this package, and is extended every 10 seconds while the request is served.
+// Complete, Extend and Release must change the record only while the reservation with the token
+// is held, and return ErrIdempotencyKeyLost otherwise.
+type IdempotencyStore interface {
+	// Reserve records key as in progress with the fingerprint and a new token, and reports true.
+	// If key is already recorded, it returns the existing record and false.
+	Reserve(ctx context.Context, key, fingerprint string) (IdempotencyRecord, bool, error)
+	// Complete replaces the reservation of key held with rec.Token by rec, holding the captured response.
+	Complete(ctx context.Context, key string, rec IdempotencyRecord) error
+	// Extend extends the reservation of key held with token.
+	Extend(ctx context.Context, key, token string) error
+	// Release removes the reservation of key held with token, so the request can be retried.
+	Release(ctx context.Context, key, token string) error
+}
+
+// Idempotency returns a middleware that makes POST and PATCH requests carrying the Idempotency-Key header
+// safe to retry. The response to the first request is captured in store along with a fingerprint of the request
+// method, path and body. Retries with the same key get the stored response with Idempotent-Replayed: true,
+// retries while the first request is in progress get 409 Conflict, and requests reusing the key for
+// a different payload get 422 Unprocessable Entity.
+//
+// Keys are scoped by scope, e.g. KeyByJWTSubject, so clients can not replay the responses of others.
+// A nil scope uses the key alone. Responses with a 5xx status are not stored, so the request can be retried.
+// If the store fails, the request is rejected with 503 Service Unavailable. Request bodies over 1 MiB
+// are rejected with 413 Request Entity Too Large.
+func Idempotency(store IdempotencyStore, scope KeyFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				_ = WriteError(w, NewError(http.StatusBadRequest, "idempotency key is too long"))
+				return
+			}
+
+			if scope != nil {
+				key = scope(r) + ":" + key
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					_ = WriteError(w, NewError(http.StatusRequestEntityTooLarge, ""))
+				} else {
+					_ = WriteError(w, NewError(http.StatusBadRequest, "failed to read request body"))
+				}
+
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			rec, reserved, err := store.Reserve(r.Context(), key, fingerprint)
+			switch {
+			case err != nil:
+				_ = WriteError(w, NewError(http.StatusServiceUnavailable, "idempotency store unavailable"))
+			case !reserved && rec.Fingerprint != fingerprint:
+				_ = WriteError(w, NewError(http.StatusUnprocessableEntity, "idempotency key was used for another request"))
+			case !reserved && rec.Response == nil:
+				_ = WriteError(w, NewError(http.StatusConflict, "request with the idempotency key is in progress"))
+			case !reserved:
+				replay(w, rec.Response)
+			default:
+				serveIdempotent(w, r, next, store, key, rec)
+			}
+		})
+	}
+}
+
+// serveIdempotent serves the request reserved with the record, capturing the response in store.
+func serveIdempotent(w http.ResponseWriter, r *http.Request, next http.Handler, store IdempotencyStore, key string, reserved IdempotencyRecord) {
+	// The record must be updated even if the client is gone.
+	ctx := context.WithoutCancel(r.Context())
+
+	iw := &idempotencyWriter{ResponseWriter: w}
+	completed := false
+
+	defer func() {
+		if !completed {
+			_ = store.Release(ctx, key, reserved.Token)
+		}
+	}()
+
+	stopExtending := extendReservation(ctx, store, key, reserved.Token)
+	defer stopExtending()
+
+	next.ServeHTTP(iw, r)
+	stopExtending()
+
+	if iw.status == 0 {
+		iw.status = http.StatusOK
+		iw.header = w.Header().Clone()
+	}
+
+	if iw.status >= http.StatusInternalServerError {
+		return
+	}
+
+	rec := IdempotencyRecord{
+		Fingerprint: reserved.Fingerprint,
+		Token:       reserved.Token,
+		Response: &IdempotentResponse{
+			Status: iw.status,
+			Header: iw.header,
+			Body:   iw.buf.Bytes(),
+		},
+	}
+
+	completed = store.Complete(ctx, key, rec) == nil
+}
+
+// extendReservation extends the reservation of key held with token in the background.
+// The returned function stops extending it and waits for the last extension to finish.
+func extendReservation(ctx context.Context, store IdempotencyStore, key, token string) func() {
+	var (
+		stop    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(idempotencyExtendInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = store.Extend(ctx, key, token)
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() {
+		close(stop)
+		<-stopped
+	})
+}
+
+// replay writes the stored response. Headers already set by the outer middlewares, such as the request ID, are kept.
+func replay(w http.ResponseWriter, resp *IdempotentResponse) {
+	h := w.Header()
+	for k, v := range resp.Header {
+		if _, ok := h[k]; !ok {
+			h[k] = v
+		}
+	}
+
+	h.Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// requestFingerprint returns the SHA-256 hash of the request method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyWriter captures the response while writing it to the client.
+type idempotencyWriter struct {
+	http.ResponseWriter
+
+	status int
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (w *idempotencyWriter) WriteHeader(code int) {
+	// Informational responses are not captured.
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+		w.header = w.Header().Clone()
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.buf.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController.
+func (w *idempotencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// mustPositiveIdempotencyTTL panics if ttl is not positive.
+func mustPositiveIdempotencyTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		panic(fmt.Sprintf("httpserver: invalid idempotency ttl %v", ttl))
+	}
+}
+
+// idempotencyEntry is a record kept by MemoryIdempotencyStore.
+type idempotencyEntry struct {
+	rec     IdempotencyRecord
+	expires time.Time
+}
+
+// MemoryIdempotencyStore keeps idempotency records in memory.
+// Expired records are removed in the background.
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+// NewMemoryIdempotencyStore creates a store keeping completed records for ttl.
+// The background cleanup stops when ctx is cancelled. It panics if ttl is not positive.
+func NewMemoryIdempotencyStore(ctx context.Context, ttl time.Duration) *MemoryIdempotencyStore {
+	return newMemoryIdempotencyStore(ctx, ttl, time.Now)
+}
+
+func newMemoryIdempotencyStore(ctx context.Context, ttl time.Duration, now func() time.Time) *MemoryIdempotencyStore {
+	mustPositiveIdempotencyTTL(ttl)
+
+	s := &MemoryIdempotencyStore{
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]idempotencyEntry),
+	}
+
+	go s.cleanup(ctx, ttl)
+
+	return s
+}
+
+// Reserve records key as in progress, unless it is already recorded.
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key, fingerprint string) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return e.rec, false, nil
+	}
+
+	rec := IdempotencyRecord{Fingerprint: fingerprint, Token: uuid.NewString()}
+	s.entries[key] = idempotencyEntry{rec: rec, expires: now.Add(idempotencyLockTTL)}
+
+	return rec, true, nil
+}
+
+// Complete replaces the reservation of key by rec.
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.reserved(key, rec.Token, now) {
+		return ErrIdempotencyKeyLost
+	}
+
+	s.entries[key] = idempotencyEntry{rec: rec, expires: now.Add(s.ttl)}
+
+	return nil
+}
+
+// Extend extends the reservation of key.
+func (s *MemoryIdempotencyStore) Extend(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.reserved(key, token, now) {
+		return ErrIdempotencyKeyLost
+	}
+
+	s.entries[key] = idempotencyEntry{rec: s.entries[key].rec, expires: now.Add(idempotencyLockTTL)}
+
+	return nil
+}
+
+// Release removes the reservation of key.
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.reserved(key, token, s.now()) {
+		return ErrIdempotencyKeyLost
+	}
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+// reserved reports whether key is reserved with token at now. It must be called with s.mu held.
+func (s *MemoryIdempotencyStore) reserved(key, token string, now time.Time) bool {
+	e, ok := s.entries[key]
+	return ok && now.Before(e.expires) && e.rec.Token == token && e.rec.Response == nil
+}
+
+// cleanup removes the expired records.
+func (s *MemoryIdempotencyStore) cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+
+			now := s.now()
+			for key, e := range s.entries {
+				if !now.Before(e.expires) {
+					delete(s.entries, key)
+				}
+			}
+
+			s.mu.Unlock()
+		}
+	}
+}
+
+// redisReservedScript returns 0 unless KEYS[1] is reserved with the token ARGV[1].
+const redisReservedScript = `
+local data = redis.call("GET", KEYS[1])
+if not data then
+	return 0
+end
+local rec = cjson.decode(data)
+if rec.token ~= ARGV[1] or rec.response ~= nil then
+	return 0
+end
+`
+
+var (
+	// redisCompleteScript replaces the reservation by the record ARGV[2] kept for ARGV[3] milliseconds.
+	redisCompleteScript = goredis.NewScript(redisReservedScript + `redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1`)
+	// redisExtendScript extends the reservation by ARGV[2] milliseconds.
+	redisExtendScript = goredis.NewScript(redisReservedScript + `redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1`)
+	// redisReleaseScript removes the reservation.
+	redisReleaseScript = goredis.NewScript(redisReservedScript + `redis.call("DEL", KEYS[1])
+return 1`)
+)
+
+// RedisIdempotencyStore keeps idempotency records in redis as JSON under prefixed keys.
+type RedisIdempotencyStore struct {
+	client *goredis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisIdempotencyStore creates a store keeping completed records in r for ttl under keys starting
+// with prefix. It panics if ttl is not positive.
+func NewRedisIdempotencyStore(r *redis.Redis, prefix string, ttl time.Duration) *RedisIdempotencyStore {
+	mustPositiveIdempotencyTTL(ttl)
+
+	return &RedisIdempotencyStore{
+		client: r.Client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// Reserve records key as in progress, unless it is already recorded.
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string) (IdempotencyRecord, bool, error) {
+	rec := IdempotencyRecord{Fingerprint: fingerprint, Token: uuid.NewString()}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+
+	// The record may expire between SETNX and GET, in which case the reservation is retried.
+	for i := 0; i < redisReserveAttempts; i++ {
+		ok, err := s.client.SetNX(ctx, s.prefix+key, data, idempotencyLockTTL).Result()
+		if err != nil {
+			return IdempotencyRecord{}, false, err
+		}
+
+		if ok {
+			return rec, true, nil
+		}
+
+		stored, err := s.client.Get(ctx, s.prefix+key).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+
+		if err != nil {
+			return IdempotencyRecord{}, false, err
+		}
+
+		var existing IdempotencyRecord
+		if err := json.Unmarshal(stored, &existing); err != nil {
+			return IdempotencyRecord{}, false, err
+		}
+
+		return existing, false, nil
+	}
+
+	return IdempotencyRecord{}, false, errors.New("httpserver: failed to reserve idempotency key")
+}
+
+// Complete replaces the reservation of key by rec.
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, rec IdempotencyRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return s.run(ctx, redisCompleteScript, key, rec.Token, data, s.ttl.Milliseconds())
+}
+
+// Extend extends the reservation of key.
+func (s *RedisIdempotencyStore) Extend(ctx context.Context, key, token string) error {
+	return s.run(ctx, redisExtendScript, key, token, idempotencyLockTTL.Milliseconds())
+}
+
+// Release removes the reservation of key.
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	return s.run(ctx, redisReleaseScript, key, token)
+}
+
+// run runs the script on the reservation of key held with token.
+func (s *RedisIdempotencyStore) run(ctx context.Context, script *goredis.Script, key, token string, args ...any) error {
+	ok, err := script.Run(ctx, s.client, []string{s.prefix + key}, append([]any{token}, args...)...).Int()
+	if err != nil {
+		return err
+	}
+
+	if ok == 0 {
+		return ErrIdempotencyKeyLost
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/romankravchuk/nix/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Idempotency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+		store   = NewMemoryIdempotencyStore(ctx, time.Minute)
+	)
+
+	h := Idempotency(store, KeyByHeader("X-Tenant"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Location", "/orders/1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("order 1"))
+	}))
+
+	do := func(target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-Tenant", "a")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := do("/orders", "k1", `{"qty":1}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = do("/orders", "k1", `{"qty":1}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "order 1", rec.Body.String())
+	assert.Equal(t, "/orders/1", rec.Header().Get("Location"))
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), calls.Load(), "replay does not call the handler")
+
+	rec = do("/orders", "k1", `{"qty":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Equal(t, http.StatusCreated, do("/slow", "k2", "").Code)
+	}()
+
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusConflict, do("/slow", "k2", "").Code)
+	close(release)
+	<-done
+
+	require.Equal(t, http.StatusInternalServerError, do("/fail", "k3", "").Code)
+	require.Equal(t, http.StatusInternalServerError, do("/fail", "k3", "").Code)
+	assert.Equal(t, int32(4), calls.Load(), "failed requests can be retried")
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader), "safe methods are not tracked")
+}
+
+// fakeClock is a clock advanced by the test.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func Test_IdempotencyStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type testCase struct {
+		name    string
+		store   IdempotencyStore
+		advance func(d time.Duration)
+	}
+
+	clock := newFakeClock()
+
+	mr := miniredis.RunT(t)
+	rd, err := redis.New("redis://" + mr.Addr())
+	require.NoError(t, err)
+
+	testCases := []testCase{
+		{
+			name:    "memory",
+			store:   newMemoryIdempotencyStore(ctx, time.Minute, clock.Now),
+			advance: clock.Add,
+		},
+		{
+			name:    "redis",
+			store:   NewRedisIdempotencyStore(rd, "idempotency:", time.Minute),
+			advance: mr.FastForward,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.store
+			response := &IdempotentResponse{Status: http.StatusCreated, Body: []byte("order 1")}
+
+			first, reserved, err := store.Reserve(ctx, "k", "a")
+			require.NoError(t, err)
+			require.True(t, reserved)
+			require.NotEmpty(t, first.Token)
+
+			rec, reserved, err := store.Reserve(ctx, "k", "a")
+			require.NoError(t, err)
+			require.False(t, reserved)
+			assert.Equal(t, "a", rec.Fingerprint)
+			assert.Nil(t, rec.Response)
+
+			tc.advance(idempotencyLockTTL - time.Second)
+			require.NoError(t, store.Extend(ctx, "k", first.Token))
+			tc.advance(idempotencyLockTTL - time.Second)
+
+			_, reserved, err = store.Reserve(ctx, "k", "a")
+			require.NoError(t, err)
+			require.False(t, reserved, "the extended reservation is held")
+
+			// The first request outlives its reservation, and a retry reserves the key again.
+			tc.advance(idempotencyLockTTL)
+
+			second, reserved, err := store.Reserve(ctx, "k", "b")
+			require.NoError(t, err)
+			require.True(t, reserved)
+			require.NotEqual(t, first.Token, second.Token)
+
+			stale := IdempotencyRecord{Fingerprint: "a", Token: first.Token, Response: response}
+			require.ErrorIs(t, store.Complete(ctx, "k", stale), ErrIdempotencyKeyLost)
+			require.ErrorIs(t, store.Extend(ctx, "k", first.Token), ErrIdempotencyKeyLost)
+			require.ErrorIs(t, store.Release(ctx, "k", first.Token), ErrIdempotencyKeyLost)
+
+			rec, reserved, err = store.Reserve(ctx, "k", "b")
+			require.NoError(t, err)
+			require.False(t, reserved)
+			assert.Equal(t, "b", rec.Fingerprint, "the reservation of the retry is kept")
+			assert.Nil(t, rec.Response)
+
+			require.NoError(t, store.Complete(ctx, "k", IdempotencyRecord{Fingerprint: "b", Token: second.Token, Response: response}))
+			require.ErrorIs(t, store.Release(ctx, "k", second.Token), ErrIdempotencyKeyLost, "a completed record is kept")
+
+			tc.advance(idempotencyLockTTL)
+
+			rec, reserved, err = store.Reserve(ctx, "k", "b")
+			require.NoError(t, err)
+			require.False(t, reserved, "a completed record is kept for the ttl")
+			assert.Equal(t, response, rec.Response)
+
+			tc.advance(time.Minute)
+
+			_, reserved, err = store.Reserve(ctx, "k", "c")
+			require.NoError(t, err)
+			assert.True(t, reserved)
+
+			released, _, err := store.Reserve(ctx, "released", "a")
+			require.NoError(t, err)
+			require.NoError(t, store.Release(ctx, "released", released.Token))
+
+			_, reserved, err = store.Reserve(ctx, "released", "a")
+			require.NoError(t, err)
+			assert.True(t, reserved, "a released key can be reserved again")
+		})
+	}
+}
+
+func Test_NewMemoryIdempotencyStore_invalidTTL(t *testing.T) {
+	assert.Panics(t, func() { NewMemoryIdempotencyStore(context.Background(), 0) })
+}
+
+func Test_Idempotency_bodyLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Idempotency(NewMemoryIdempotencyStore(ctx, time.Minute), nil)(helloHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(strings.Repeat("a", maxIdempotentBodyBytes+1)))
+	req.Header.Set(IdempotencyKeyHeader, "k")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
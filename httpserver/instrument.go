@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver/metrics"
+)
+
+const (
+	// unknownRoute labels the requests that were not tagged with a route, see Route.
+	unknownRoute = "unknown"
+	// otherMethod labels the requests with a non-standard method, which is chosen by the client.
+	otherMethod = "OTHER"
+)
+
+// sizeBuckets are the histogram buckets of the response sizes in bytes.
+var sizeBuckets = []float64{100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// Instrument returns a middleware that records HTTP metrics in reg:
+//
+//   - http_requests_total, counter by method, route and status code;
+//   - http_request_duration_seconds, histogram by method and route;
+//   - http_response_size_bytes, histogram by method and route;
+//   - http_requests_in_flight, gauge.
+//
+// The route label is the template set with Route, or "unknown". The method label is the method
+// of the request if it is one of RFC 9110 or PATCH, or "OTHER", so clients can not create new series.
+func Instrument(reg *metrics.Registry) Middleware {
+	var (
+		requests = reg.NewCounter("http_requests_total",
+			"Total number of HTTP requests.", "method", "route", "code")
+		duration = reg.NewHistogram("http_request_duration_seconds",
+			"Duration of HTTP requests in seconds.", metrics.DefBuckets, "method", "route")
+		size = reg.NewHistogram("http_response_size_bytes",
+			"Size of HTTP responses in bytes.", sizeBuckets, "method", "route")
+		inFlight = reg.NewGauge("http_requests_in_flight",
+			"Number of HTTP requests being served.")
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start = time.Now()
+				rw    = wrapResponseWriter(w)
+			)
+
+			r, holder := withRouteHolder(r)
+
+			inFlight.Inc()
+			defer func() {
+				inFlight.Dec()
+
+				route := holder.name
+				if route == "" {
+					route = unknownRoute
+				}
+
+				method := methodLabel(r.Method)
+
+				requests.Inc(method, route, strconv.Itoa(rw.Status()))
+				duration.Observe(time.Since(start).Seconds(), method, route)
+				size.Observe(float64(rw.bytes), method, route)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// methodLabel returns the method label of a request with the method.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
@@ -0,0 +1,183 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/romankravchuk/nix/validator"
+)
+
+const defaultMaxJSONBytes = 1 << 20
+
+// ProblemContentType is the content type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Error is an RFC 7807 problem detail. It is rendered as application/problem+json by WriteError.
+type Error struct {
+	// Type is a URI identifying the problem type, "about:blank" if empty.
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type.
+	Title string `json:"title"`
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI identifying this occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Errors holds the validation errors by field name.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// NewError creates a problem with the given status, titled with the status text.
+func NewError(status int, detail string) *Error {
+	return &Error{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Title
+	}
+
+	return e.Title + ": " + e.Detail
+}
+
+// WriteJSON writes v as JSON with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	return json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes err as application/problem+json. If err is not an *Error,
+// a generic 500 Internal Server Error is written, so internal details do not leak.
+func WriteError(w http.ResponseWriter, err error) error {
+	var problem *Error
+	if !errors.As(err, &problem) {
+		problem = NewError(http.StatusInternalServerError, "")
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// errTrailingData is the error of a body holding more than a single JSON value.
+var errTrailingData = errors.New("body must contain a single JSON value")
+
+// decodeOptions holds the settings of DecodeJSON.
+type decodeOptions struct {
+	maxBytes  int64
+	validator *validator.Validator
+}
+
+type DecodeOption func(o *decodeOptions)
+
+// DecodeMaxBytes limits the size of the body, 1MiB by default.
+func DecodeMaxBytes(n int64) DecodeOption {
+	return func(o *decodeOptions) {
+		o.maxBytes = n
+	}
+}
+
+// DecodeValidator validates the decoded value with v.
+func DecodeValidator(v *validator.Validator) DecodeOption {
+	return func(o *decodeOptions) {
+		o.validator = v
+	}
+}
+
+// DecodeJSON decodes the JSON body of r into dst, rejecting unknown fields and trailing data.
+// The returned errors are *Error ready for WriteError:
+//
+//   - 415 Unsupported Media Type if the content type is set and is not JSON;
+//   - 413 Request Entity Too Large if the body is over the size limit;
+//   - 400 Bad Request if the body is empty or malformed;
+//   - 422 Unprocessable Entity if the validation fails, with the failures in Errors;
+//   - 500 Internal Server Error if dst can not be validated, e.g. it is not a pointer to a struct.
+func DecodeJSON(r *http.Request, dst any, opts ...DecodeOption) error {
+	o := decodeOptions{maxBytes: defaultMaxJSONBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && mediaType != "application/problem+json") {
+			return NewError(http.StatusUnsupportedMediaType, "content type must be application/json")
+		}
+	}
+
+	// One byte over the limit is let through to detect a too large body.
+	body := &io.LimitedReader{R: r.Body, N: o.maxBytes + 1}
+
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		if _, tokenErr := dec.Token(); !errors.Is(tokenErr, io.EOF) {
+			err = errTrailingData
+		}
+	}
+
+	if body.N <= 0 {
+		return NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", o.maxBytes))
+	}
+
+	if err != nil {
+		return decodeError(err)
+	}
+
+	if o.validator == nil {
+		return nil
+	}
+
+	fields, err := o.validator.Check(dst)
+	if err != nil {
+		return NewError(http.StatusInternalServerError, "")
+	}
+
+	if len(fields) > 0 {
+		problem := NewError(http.StatusUnprocessableEntity, "validation failed")
+		problem.Errors = fields
+
+		return problem
+	}
+
+	return nil
+}
+
+// decodeError converts an error of the JSON decoder into a problem.
+func decodeError(err error) error {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		return NewError(http.StatusBadRequest, "body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return NewError(http.StatusBadRequest, "body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return NewError(http.StatusBadRequest, fmt.Sprintf("body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return NewError(http.StatusBadRequest, fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type))
+	default:
+		// Unknown fields and trailing data are reported as is, e.g. `json: unknown field "name"`.
+		return NewError(http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/romankravchuk/nix/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type createUser struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+func Test_DecodeJSON(t *testing.T) {
+	type testCase struct {
+		name           string
+		body           string
+		contentType    string
+		opts           []DecodeOption
+		expectedStatus int
+		expectedErrors []string
+	}
+
+	testCases := []testCase{
+		{
+			name: "valid",
+			body: `{"name":"john","email":"john@example.com"}`,
+			opts: []DecodeOption{DecodeValidator(validator.New())},
+		},
+		{
+			name:           "empty body",
+			body:           "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed",
+			body:           `{"name":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "syntax error",
+			body:           `{"name" "john"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong type",
+			body:           `{"name":1}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown field",
+			body:           `{"name":"john","admin":true}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "trailing data",
+			body:           `{"name":"john"} {}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too large",
+			body:           `{"name":"` + strings.Repeat("a", 64) + `"}`,
+			opts:           []DecodeOption{DecodeMaxBytes(32)},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "unsupported media type",
+			body:           `{"name":"john"}`,
+			contentType:    "text/plain",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "validation failed",
+			body:           `{"email":"john"}`,
+			opts:           []DecodeOption{DecodeValidator(validator.New())},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: []string{"Name", "Email"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			var dst createUser
+			err := DecodeJSON(req, &dst, tc.opts...)
+
+			if tc.expectedStatus == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var problem *Error
+			require.True(t, errors.As(err, &problem))
+			assert.Equal(t, tc.expectedStatus, problem.Status)
+
+			for _, field := range tc.expectedErrors {
+				assert.Contains(t, problem.Errors, field)
+			}
+		})
+	}
+}
+
+func Test_DecodeJSON_notStruct(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[1,2]`))
+
+	var dst []int
+	err := DecodeJSON(req, &dst, DecodeValidator(validator.New()))
+
+	var problem *Error
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+}
+
+func Test_WriteError(t *testing.T) {
+	type testCase struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedDetail string
+	}
+
+	testCases := []testCase{
+		{
+			name:           "problem",
+			err:            NewError(http.StatusNotFound, "user not found"),
+			expectedStatus: http.StatusNotFound,
+			expectedDetail: "user not found",
+		},
+		{
+			name:           "internal error",
+			err:            errors.New("pq: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			require.NoError(t, WriteError(rec, tc.err))
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+			var problem Error
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+			assert.Equal(t, tc.expectedStatus, problem.Status)
+			assert.Equal(t, http.StatusText(tc.expectedStatus), problem.Title)
+			assert.Equal(t, tc.expectedDetail, problem.Detail)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package httpserver
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// unixSocket describes the Unix-domain socket the server listens on.
+type unixSocket struct {
+	path string
+	mode fs.FileMode
+}
+
+// listen returns the listener the server should serve on.
+// A listener passed with the Listener option takes precedence over an inherited one,
+// see inheritedListeners, then come the Unix socket and the TCP address.
+// An inherited admin listener is kept for listenAdmin.
+func (s *Server) listen() (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return s.listener, nil
+	}
+
+	ln, admin, err := inheritedListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	if admin != nil && s.admin.addr == "" {
+		_ = admin.Close()
+		admin = nil
+	}
+
+	s.adminListener = admin
+
+	switch {
+	case ln != nil:
+	case s.unix != nil:
+		ln, err = listenUnix(s.unix.path, s.unix.mode)
+	default:
+		ln, err = net.Listen("tcp", s.server.Addr)
+	}
+
+	if err != nil {
+		closeListeners(admin)
+		s.adminListener = nil
+
+		return nil, err
+	}
+
+	s.listener = ln
+
+	return ln, nil
+}
+
+// listenAdmin returns the listener the admin server should serve on,
+// the inherited one if any or a new one bound to the admin address.
+// It must be called after listen.
+func (s *Server) listenAdmin() (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.adminListener != nil {
+		return s.adminListener, nil
+	}
+
+	ln, err := net.Listen("tcp", s.admin.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.adminListener = ln
+
+	return ln, nil
+}
+
+// listenUnix binds a Unix-domain socket at path, removing a stale socket file first.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+
+	return ln, nil
+}
+
+// Addr returns the address the server is bound to.
+// It returns nil until the server starts listening.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+// AdminAddr returns the address the admin server is bound to.
+// It returns nil until the admin server starts listening or if there is none, see Admin.
+func (s *Server) AdminAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.adminListener == nil {
+		return nil
+	}
+
+	return s.adminListener.Addr()
+}
@@ -0,0 +1,196 @@
+package httpserver
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxInFlight   = 100
+	defaultTargetLatency = 100 * time.Millisecond
+	// loadShedBackoff is the multiplicative decrease of the adaptive limit.
+	loadShedBackoff = 0.9
+)
+
+// LoadShedConfig configures the LoadShed middleware.
+type LoadShedConfig struct {
+	// MaxInFlight is the number of requests served concurrently, 100 by default.
+	// In adaptive mode it is the initial limit.
+	MaxInFlight int
+	// MaxQueue is the number of requests allowed to wait for a slot. Zero disables the queue.
+	MaxQueue int
+	// QueueTimeout is how long a request waits for a slot. Zero waits until the request is cancelled.
+	QueueTimeout time.Duration
+
+	// Adaptive makes the limit follow the latency of the requests, counting the wait in the queue:
+	// it decreases multiplicatively when a request is slower than TargetLatency, and increases
+	// additively otherwise.
+	Adaptive bool
+	// TargetLatency is the latency above which the adaptive limit decreases, 100ms by default.
+	TargetLatency time.Duration
+	// MinInFlight and MaxInFlightLimit bound the adaptive limit, 1 and 10 times MaxInFlight by default.
+	MinInFlight      int
+	MaxInFlightLimit int
+}
+
+// LoadShed returns a middleware that limits the number of requests served concurrently.
+// Requests over the limit wait in a bounded queue, and get 503 Service Unavailable
+// when the queue is full or the wait times out.
+func LoadShed(cfg LoadShedConfig) Middleware {
+	return loadShed(newConcurrencyLimiter(cfg))
+}
+
+func loadShed(l *concurrencyLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The latency includes the wait in the queue, so queueing alone lowers the adaptive limit.
+			start := time.Now()
+
+			if !l.acquire(r.Context()) {
+				w.Header().Set("Retry-After", "1")
+				_ = WriteError(w, NewError(http.StatusServiceUnavailable, "server overloaded"))
+				return
+			}
+
+			defer func() {
+				l.release(time.Since(start))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// concurrencyLimiter is a semaphore with a FIFO wait queue and an optionally adaptive size.
+type concurrencyLimiter struct {
+	cfg LoadShedConfig
+
+	mu          sync.Mutex
+	limit       float64
+	inFlight    int
+	waiters     list.List
+	lastBackoff time.Time
+}
+
+func newConcurrencyLimiter(cfg LoadShedConfig) *concurrencyLimiter {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultMaxInFlight
+	}
+
+	if cfg.MinInFlight <= 0 {
+		cfg.MinInFlight = 1
+	}
+
+	if cfg.MaxInFlightLimit <= 0 {
+		cfg.MaxInFlightLimit = 10 * cfg.MaxInFlight
+	}
+
+	if cfg.TargetLatency <= 0 {
+		cfg.TargetLatency = defaultTargetLatency
+	}
+
+	return &concurrencyLimiter{
+		cfg:   cfg,
+		limit: float64(cfg.MaxInFlight),
+	}
+}
+
+// acquire takes a slot, waiting in the queue if needed. It reports whether a slot was taken.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+
+	if l.inFlight < l.current() && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+
+		return true
+	}
+
+	if l.waiters.Len() >= l.cfg.MaxQueue {
+		l.mu.Unlock()
+		return false
+	}
+
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.cfg.QueueTimeout > 0 {
+		timer := time.NewTimer(l.cfg.QueueTimeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return true
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-ready:
+		// The slot was handed over while timing out, give it back.
+		l.inFlight--
+		l.grant()
+	default:
+		l.waiters.Remove(elem)
+	}
+
+	return false
+}
+
+// release frees a slot taken for a request that lasted latency.
+func (l *concurrencyLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.Adaptive {
+		l.adapt(latency)
+	}
+
+	l.inFlight--
+	l.grant()
+}
+
+// grant hands the free slots to the waiters in FIFO order.
+func (l *concurrencyLimiter) grant() {
+	for l.inFlight < l.current() && l.waiters.Len() > 0 {
+		ready := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.inFlight++
+		close(ready)
+	}
+}
+
+// adapt updates the limit from the latency of a request, AIMD-style. The limit is decreased
+// at most once per target latency, so a burst of slow requests counts as a single congestion signal.
+// It is increased only while the limit is actually used.
+func (l *concurrencyLimiter) adapt(latency time.Duration) {
+	now := time.Now()
+
+	switch {
+	case latency > l.cfg.TargetLatency:
+		if now.Sub(l.lastBackoff) < l.cfg.TargetLatency {
+			return
+		}
+
+		l.limit = math.Max(float64(l.cfg.MinInFlight), l.limit*loadShedBackoff)
+		l.lastBackoff = now
+	case float64(l.inFlight) >= l.limit/2:
+		l.limit = math.Min(float64(l.cfg.MaxInFlightLimit), l.limit+1/l.limit)
+	}
+}
+
+// current returns the limit as a whole number of requests.
+func (l *concurrencyLimiter) current() int {
+	return int(l.limit)
+}
@@ -0,0 +1,142 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadShed(t *testing.T) {
+	var (
+		started = make(chan struct{}, 2)
+		unblock = make(chan struct{})
+	)
+
+	h := LoadShed(LoadShedConfig{MaxInFlight: 1, MaxQueue: 1})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	}))
+
+	serve := func() <-chan int {
+		code := make(chan int, 1)
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			code <- rec.Code
+		}()
+
+		return code
+	}
+
+	first := serve()
+	<-started
+
+	queued := serve()
+	require.Never(t, func() bool { return len(started) > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, <-serve())
+
+	unblock <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-first)
+
+	<-started
+	close(unblock)
+	assert.Equal(t, http.StatusOK, <-queued)
+}
+
+func Test_LoadShed_QueueTimeout(t *testing.T) {
+	l := newConcurrencyLimiter(LoadShedConfig{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond})
+
+	require.True(t, l.acquire(context.Background()))
+	require.False(t, l.acquire(context.Background()))
+	assert.Equal(t, 0, l.waiters.Len())
+
+	l.release(0)
+	require.True(t, l.acquire(context.Background()))
+}
+
+func Test_concurrencyLimiter_adapt(t *testing.T) {
+	l := newConcurrencyLimiter(LoadShedConfig{
+		MaxInFlight:      10,
+		Adaptive:         true,
+		TargetLatency:    time.Millisecond,
+		MinInFlight:      9,
+		MaxInFlightLimit: 11,
+	})
+
+	l.adapt(time.Second)
+	assert.Equal(t, 9, l.current())
+
+	l.adapt(time.Second)
+	assert.Equal(t, 9, l.current(), "decreased once per target latency")
+
+	l.lastBackoff = time.Time{}
+	l.adapt(time.Second)
+	assert.Equal(t, 9, l.current(), "bounded by the minimum")
+
+	l.inFlight = 8
+	for i := 0; i < 100; i++ {
+		l.adapt(0)
+	}
+	assert.Equal(t, 11, l.current(), "bounded by the maximum")
+}
+
+func Test_concurrencyLimiter_adapt_defaultTargetLatency(t *testing.T) {
+	l := newConcurrencyLimiter(LoadShedConfig{MaxInFlight: 10, Adaptive: true})
+	l.inFlight = 9
+
+	for i := 0; i < 100; i++ {
+		l.adapt(time.Millisecond)
+	}
+
+	assert.Greater(t, l.current(), 10, "fast requests raise the limit")
+}
+
+func Test_LoadShed_adaptiveQueueLatency(t *testing.T) {
+	l := newConcurrencyLimiter(LoadShedConfig{
+		MaxInFlight:   4,
+		MaxQueue:      1,
+		Adaptive:      true,
+		TargetLatency: 20 * time.Millisecond,
+	})
+
+	// The slots are held outside of the middleware, so only the queued request reports a latency.
+	for i := 0; i < 4; i++ {
+		require.True(t, l.acquire(context.Background()))
+	}
+
+	h := loadShed(l)(helloHandler)
+
+	done := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		done <- rec.Code
+	}()
+
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		return l.waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+
+	l.mu.Lock()
+	l.inFlight--
+	l.grant()
+	l.mu.Unlock()
+
+	require.Equal(t, http.StatusOK, <-done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	assert.Less(t, l.current(), 4, "a fast handler behind a slow queue lowers the limit")
+}
@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maintenanceState is the state of the maintenance mode of the server.
+type maintenanceState struct {
+	retryAfter time.Duration
+}
+
+// SetMaintenance turns the maintenance mode on or off. In maintenance mode, the requests to paths
+// not allowed by MaintenanceAllow get 503 Service Unavailable with Retry-After set to retryAfter,
+// unless it is zero. The built-in endpoints, such as the health checks, are always served.
+// It is safe to call SetMaintenance while the server is running.
+func (s *Server) SetMaintenance(on bool, retryAfter time.Duration) {
+	if !on {
+		s.maintenance.Store(nil)
+		return
+	}
+
+	s.maintenance.Store(&maintenanceState{retryAfter: retryAfter})
+}
+
+// InMaintenance reports whether the maintenance mode is on.
+func (s *Server) InMaintenance() bool {
+	return s.maintenance.Load() != nil
+}
+
+// SwapHandler atomically replaces the handler of the server, keeping its middlewares.
+// Requests in progress finish with the previous handler. A nil handler means http.DefaultServeMux.
+func (s *Server) SwapHandler(h http.Handler) {
+	s.handler.store(h)
+}
+
+// maintenanceGuard returns a handler that rejects the requests to the disallowed paths
+// in maintenance mode and passes others to next.
+func (s *Server) maintenanceGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.maintenance.Load()
+		if state == nil || s.maintenanceAllowed(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if state.retryAfter > 0 {
+			w.Header().Set("Retry-After", ceilSeconds(state.retryAfter))
+		}
+
+		_ = WriteError(w, NewError(http.StatusServiceUnavailable, "service is under maintenance"))
+	})
+}
+
+// maintenanceAllowed reports whether the path is served in maintenance mode.
+// An allowed path ending with a slash allows the whole subtree. The path is cleaned first,
+// so dot segments can not reach a disallowed path through an allowed one.
+func (s *Server) maintenanceAllowed(p string) bool {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	for _, allowed := range s.maintenanceAllow {
+		if cleaned == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(cleaned, allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// swapHandler is a handler that can be replaced while serving.
+type swapHandler struct {
+	h atomic.Pointer[http.Handler]
+}
+
+func newSwapHandler(h http.Handler) *swapHandler {
+	sh := &swapHandler{}
+	sh.store(h)
+
+	return sh
+}
+
+func (sh *swapHandler) store(h http.Handler) {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	sh.h.Store(&h)
+}
+
+func (sh *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*sh.h.Load()).ServeHTTP(w, r)
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server_SetMaintenance(t *testing.T) {
+	srv := New(context.Background(), helloHandler,
+		Health(health.New()),
+		MaintenanceAllow("/admin/"),
+	)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, do("/orders").Code)
+
+	srv.SetMaintenance(true, 90*time.Second)
+	assert.True(t, srv.InMaintenance())
+
+	rec := do("/orders")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "90", rec.Header().Get("Retry-After"))
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusOK, do("/admin/migrations").Code, "allowlisted subtree is served")
+	assert.Equal(t, http.StatusOK, do("/admin/").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do("/admin/../orders").Code, "dot segments are cleaned")
+	assert.Equal(t, http.StatusServiceUnavailable, do("/admin/%2e%2e/orders").Code, "escaped dot segments are cleaned")
+	assert.Equal(t, http.StatusOK, do("/livez").Code, "health endpoints are served")
+
+	srv.SetMaintenance(false, 0)
+	assert.False(t, srv.InMaintenance())
+	assert.Equal(t, http.StatusOK, do("/orders").Code)
+}
+
+func Test_Server_SwapHandler(t *testing.T) {
+	srv := New(context.Background(), helloHandler, Use(RequestID()))
+
+	rec := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "hello", rec.Body.String())
+
+	srv.SwapHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "swapped")
+	}))
+
+	rec = httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "swapped", rec.Body.String())
+	assert.NotEmpty(t, rec.Header().Get(RequestIDHeader), "middlewares are kept")
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// value is a float64 updated atomically.
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) set(val float64) {
+	v.bits.Store(math.Float64bits(val))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+func newValue() any {
+	return &value{}
+}
+
+// Counter is a monotonically increasing metric.
+type Counter struct {
+	family *family
+}
+
+// NewCounter registers a new counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, typeCounter, labels)}
+	r.register(c.family)
+
+	return c
+}
+
+// Inc increments the counter of the label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter of the label values. A negative delta is ignored.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+
+	c.family.get(labelValues, newValue).(*value).add(delta)
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	family *family
+}
+
+// NewGauge registers a new gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, typeGauge, labels)}
+	r.register(g.family)
+
+	return g
+}
+
+// Set sets the gauge of the label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.family.get(labelValues, newValue).(*value).set(v)
+}
+
+// Add adds delta, which may be negative, to the gauge of the label values.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.family.get(labelValues, newValue).(*value).add(delta)
+}
+
+// Inc increments the gauge of the label values by one.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decrements the gauge of the label values by one.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// histogramSeries counts the observations of a single series.
+type histogramSeries struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram samples observations and counts them in buckets.
+type Histogram struct {
+	family *family
+}
+
+// NewHistogram registers a new histogram with the given upper bounds of the buckets and label names.
+// DefBuckets are used if buckets is empty. The +Inf bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{family: newFamily(name, help, typeHistogram, labels)}
+	h.family.buckets = buckets
+	r.register(h.family)
+
+	return h
+}
+
+// Observe adds the observation v to the histogram of the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.family.get(labelValues, func() any {
+		return &histogramSeries{counts: make([]uint64, len(h.family.buckets))}
+	}).(*histogramSeries)
+
+	i := sort.SearchFloat64s(h.family.buckets, v)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+
+	s.count++
+	s.sum += v
+}
@@ -0,0 +1,144 @@
+// Package metrics provides a dependency-free registry of counters, gauges and histograms
+// rendered in the Prometheus text exposition format.
+//
+// Example Usage:
+//
+//	reg := metrics.NewRegistry()
+//	jobs := reg.NewCounter("jobs_processed_total", "Number of processed jobs.", "queue")
+//	jobs.Inc("emails")
+//
+//	http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default histogram buckets, tailored to measure request durations in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSeparator joins label values into series keys. It can not appear in valid UTF-8.
+const labelSeparator = "\xff"
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// family is a named metric with a fixed set of label names and one series per label values.
+type family struct {
+	name   string
+	help   string
+	typ    metricType
+	labels []string
+
+	// buckets are the upper bounds of the histogram buckets, without +Inf.
+	buckets []float64
+
+	mu     sync.RWMutex
+	series map[string]any
+}
+
+func newFamily(name, help string, typ metricType, labels []string) *family {
+	return &family{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string]any),
+	}
+}
+
+// get returns the series of the label values, creating it with create if it does not exist.
+// It panics if the number of values does not match the number of labels.
+func (f *family) get(values []string, create func() any) any {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, labelSeparator)
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok = f.series[key]; !ok {
+		s = create()
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// keys returns the series keys in sorted order, so the output is stable.
+func (f *family) keys() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (f *family) load(key string) any {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.series[key]
+}
+
+// Registry holds metrics and renders them in the Prometheus text format.
+type Registry struct {
+	mu       sync.RWMutex
+	families []*family
+	names    map[string]struct{}
+}
+
+// NewRegistry creates a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]struct{}),
+	}
+}
+
+// register adds the family to the registry. It panics if the name is already taken.
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.names[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s is already registered", f.name))
+	}
+
+	r.names[f.name] = struct{}{}
+	r.families = append(r.families, f)
+}
+
+// ServeHTTP writes all the metrics of the registry in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	_ = r.Write(w)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Registry_Write(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounter("requests_total", "Total requests.", "method", "code")
+	requests.Inc("GET", "200")
+	requests.Add(2, "GET", "200")
+	requests.Inc("POST", "500")
+
+	inFlight := reg.NewGauge("in_flight", "In-flight requests.\nWith escaping.")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+
+	latency := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "path")
+	latency.Observe(0.05, `/a"b`)
+	latency.Observe(0.3, `/a"b`)
+	latency.Observe(2, `/a"b`)
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",code="200"} 3
+requests_total{method="POST",code="500"} 1
+# HELP in_flight In-flight requests.\nWith escaping.
+# TYPE in_flight gauge
+in_flight 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a\"b",le="0.1"} 1
+latency_seconds_bucket{path="/a\"b",le="0.5"} 2
+latency_seconds_bucket{path="/a\"b",le="+Inf"} 3
+latency_seconds_sum{path="/a\"b"} 2.35
+latency_seconds_count{path="/a\"b"} 3
+`, rec.Body.String())
+}
+
+func Test_Registry_Panics(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("dup_total", "Duplicate.", "label")
+
+	require.Panics(t, func() { reg.NewGauge("dup_total", "Duplicate.") })
+	require.Panics(t, func() { c.Inc() })
+	require.Panics(t, func() { c.Inc("a", "b") })
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// Write writes all the metrics of the registry to w in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.RLock()
+	families := append([]*family(nil), r.families...)
+	r.mu.RUnlock()
+
+	var buf bytes.Buffer
+
+	for _, f := range families {
+		writeFamily(&buf, f)
+	}
+
+	_, err := buf.WriteTo(w)
+
+	return err
+}
+
+func writeFamily(w *bytes.Buffer, f *family) {
+	w.WriteString("# HELP " + f.name + " " + helpEscaper.Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + string(f.typ) + "\n")
+
+	for _, key := range f.keys() {
+		var values []string
+		if len(f.labels) > 0 {
+			values = strings.Split(key, labelSeparator)
+		}
+
+		switch s := f.load(key).(type) {
+		case *value:
+			writeSample(w, f.name, f.labels, values, formatFloat(s.get()))
+		case *histogramSeries:
+			writeHistogram(w, f, values, s)
+		}
+	}
+}
+
+func writeHistogram(w *bytes.Buffer, f *family, values []string, s *histogramSeries) {
+	s.mu.Lock()
+	counts := append([]uint64(nil), s.counts...)
+	count, sum := s.count, s.sum
+	s.mu.Unlock()
+
+	var (
+		labels     = append(append([]string(nil), f.labels...), "le")
+		bucket     = append(append([]string(nil), values...), "")
+		cumulative uint64
+	)
+
+	for i, c := range counts {
+		cumulative += c
+		bucket[len(bucket)-1] = formatFloat(f.buckets[i])
+		writeSample(w, f.name+"_bucket", labels, bucket, formatUint(cumulative))
+	}
+
+	bucket[len(bucket)-1] = "+Inf"
+	writeSample(w, f.name+"_bucket", labels, bucket, formatUint(count))
+	writeSample(w, f.name+"_sum", f.labels, values, formatFloat(sum))
+	writeSample(w, f.name+"_count", f.labels, values, formatUint(count))
+}
+
+func writeSample(w *bytes.Buffer, name string, labels, values []string, val string) {
+	w.WriteString(name)
+
+	if len(labels) > 0 {
+		w.WriteByte('{')
+
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+
+			w.WriteString(l + `="` + labelValueEscaper.Replace(values[i]) + `"`)
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteString(" " + val + "\n")
+}
+
+// formatFloat formats v as expected by the exposition format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
@@ -0,0 +1,41 @@
+package httpserver
+
+import "net/http"
+
+// Middleware wraps an http.Handler with additional behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middlewares. The first middleware is the outermost one,
+// so it sees the request first and the response last.
+type Chain []Middleware
+
+// NewChain creates a new chain of the given middlewares.
+func NewChain(mws ...Middleware) Chain {
+	return append(Chain(nil), mws...)
+}
+
+// Append returns a new chain with mws added to the end of c. The chain c is left untouched.
+func (c Chain) Append(mws ...Middleware) Chain {
+	chain := make(Chain, 0, len(c)+len(mws))
+	chain = append(chain, c...)
+
+	return append(chain, mws...)
+}
+
+// Then wraps h with the middlewares of the chain.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc wraps fn with the middlewares of the chain.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/romankravchuk/nix/httpserver/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Chain(t *testing.T) {
+	var order []string
+
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	base := NewChain(mw("a"))
+	extended := base.Append(mw("b"), mw("c"))
+	require.Len(t, base, 1)
+
+	h := extended.ThenFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []string{"a", "b", "c", "handler"}, order)
+}
+
+func Test_RequestID(t *testing.T) {
+	type testCase struct {
+		name     string
+		incoming string
+		reused   bool
+	}
+
+	testCases := []testCase{
+		{name: "generated", incoming: ""},
+		{name: "propagated", incoming: "abc-123", reused: true},
+		{name: "malformed", incoming: "bad id"},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fromCtx string
+			h := RequestID()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				fromCtx = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.incoming != "" {
+				req.Header.Set(RequestIDHeader, tc.incoming)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.NotEmpty(t, fromCtx)
+			assert.Equal(t, fromCtx, rec.Header().Get(RequestIDHeader))
+
+			if tc.reused {
+				assert.Equal(t, tc.incoming, fromCtx)
+			} else {
+				assert.NotEqual(t, tc.incoming, fromCtx)
+			}
+		})
+	}
+}
+
+func Test_Recovery(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Recovery(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, buf.String(), `"panic":"boom"`)
+}
+
+func Test_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := NewChain(RequestID(), AccessLog(logger)).ThenFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/items", entry["path"])
+	assert.EqualValues(t, http.StatusCreated, entry["status"])
+	assert.EqualValues(t, len("created"), entry["bytes"])
+	assert.Equal(t, "10.0.0.1:1234", entry["remote_addr"])
+	assert.NotEmpty(t, entry["request_id"])
+	assert.Contains(t, entry, "latency")
+}
+
+func Test_Instrument(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.Handle("/users/", Route("/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("user"))
+	})))
+
+	h := NewChain(Instrument(reg)).Then(mux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("SCAN1", "/users/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("get", "/users/1", nil))
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+
+	out := buf.String()
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/users/{id}",code="200"} 2`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="unknown",code="404"} 1`)
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/users/{id}"} 2`)
+	assert.Contains(t, out, `http_response_size_bytes_sum{method="GET",route="/users/{id}"} 8`)
+	assert.Contains(t, out, `http_requests_total{method="OTHER",route="/users/{id}",code="200"} 2`)
+	assert.NotContains(t, out, `SCAN1`)
+	assert.Contains(t, out, `http_requests_in_flight 0`)
+}
@@ -1,9 +1,16 @@
 package httpserver
 
 import (
+	"io/fs"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"syscall"
 	"time"
+
+	"github.com/romankravchuk/nix/httpserver/health"
+	"github.com/romankravchuk/nix/httpserver/metrics"
 )
 
 type Option func(s *Server)
@@ -26,6 +33,34 @@ func WriteTimeout(timeout time.Duration) Option {
 	}
 }
 
+// ReadHeaderTimeout sets the amount of time allowed to read the request headers.
+func ReadHeaderTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.server.ReadHeaderTimeout = timeout
+	}
+}
+
+// IdleTimeout sets how long a keep-alive connection waits for the next request.
+func IdleTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.server.IdleTimeout = timeout
+	}
+}
+
+// MaxHeaderBytes limits the size of the request headers.
+func MaxHeaderBytes(n int) Option {
+	return func(s *Server) {
+		s.server.MaxHeaderBytes = n
+	}
+}
+
+// MaxBodyBytes limits the size of the request bodies. Routes can change it with BodyLimit.
+func MaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxBodyBytes = n
+	}
+}
+
 func ShutdownTimeout(timeout time.Duration) Option {
 	return func(s *Server) {
 		s.shutdownTimeout = timeout
@@ -37,3 +72,165 @@ func ErrorLogger(log *log.Logger) Option {
 		s.server.ErrorLog = log
 	}
 }
+
+// TLS makes the server speak HTTPS using the given certificate and key files.
+// The files are watched and the certificate is swapped without a restart when they change.
+func TLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.tls.certFile = certFile
+		s.tls.keyFile = keyFile
+	}
+}
+
+// TLSMinVersion sets the minimum TLS version accepted by the server, e.g. tls.VersionTLS12.
+func TLSMinVersion(version uint16) Option {
+	return func(s *Server) {
+		s.tls.minVersion = version
+	}
+}
+
+// TLSCipherSuites restricts the cipher suites used for TLS 1.0-1.2 connections.
+func TLSCipherSuites(suites ...uint16) Option {
+	return func(s *Server) {
+		s.tls.cipherSuites = suites
+	}
+}
+
+// TLSClientCA enables mutual TLS. Clients must present a certificate signed by one of the CAs in caFile.
+func TLSClientCA(caFile string) Option {
+	return func(s *Server) {
+		s.tls.clientCAFile = caFile
+	}
+}
+
+// TLSReloadInterval sets how often the certificate files are checked for changes.
+// A zero or negative interval disables reloading.
+func TLSReloadInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.tls.reloadInterval = interval
+	}
+}
+
+// Listener makes the server serve on an already bound listener instead of binding the address itself.
+func Listener(ln net.Listener) Option {
+	return func(s *Server) {
+		s.listener = ln
+	}
+}
+
+// UnixSocket makes the server listen on a Unix-domain socket at path with the given file mode.
+func UnixSocket(path string, mode fs.FileMode) Option {
+	return func(s *Server) {
+		s.unix = &unixSocket{path: path, mode: mode}
+	}
+}
+
+// GracefulRestart makes the server re-execute its binary when one of the signals is received,
+// SIGHUP by default. The listening sockets, the admin one included, are handed to the child process,
+// while the current process stops accepting connections and drains within the shutdown timeout.
+func GracefulRestart(signals ...os.Signal) Option {
+	return func(s *Server) {
+		if len(signals) == 0 {
+			signals = []os.Signal{syscall.SIGHUP}
+		}
+
+		s.restartSignals = signals
+	}
+}
+
+// Use adds middlewares that wrap the handler of the server. They are applied in the order given,
+// the first one being the outermost.
+func Use(mws ...Middleware) Option {
+	return func(s *Server) {
+		s.middlewares = s.middlewares.Append(mws...)
+	}
+}
+
+// MaintenanceAllow sets the paths served in maintenance mode, see Server.SetMaintenance.
+// A path ending with a slash allows the whole subtree.
+func MaintenanceAllow(paths ...string) Option {
+	return func(s *Server) {
+		s.maintenanceAllow = append(s.maintenanceAllow, paths...)
+	}
+}
+
+// Health serves the liveness and readiness endpoints of h on /livez and /readyz.
+// The readiness endpoint starts failing as soon as the graceful shutdown begins.
+func Health(h *health.Health) Option {
+	return func(s *Server) {
+		s.health = h
+		s.mount("/livez", h.LivenessHandler())
+		s.mount("/readyz", h.ReadinessHandler())
+	}
+}
+
+// Metrics serves the metrics of reg in the Prometheus text format on /metrics.
+// The endpoint is served by the admin server if there is one, see Admin.
+// Use Instrument to record the HTTP metrics of the server in reg.
+func Metrics(reg *metrics.Registry) Option {
+	return func(s *Server) {
+		s.metrics = reg
+	}
+}
+
+// Admin starts a second server on addr serving pprof under /debug/pprof/, expvar under /debug/vars,
+// the build info under /debug/buildinfo and the metrics. It is run and shut down together with the server.
+func Admin(addr string) Option {
+	return func(s *Server) {
+		s.admin.addr = addr
+	}
+}
+
+// AdminLogLevel exposes level on /debug/loglevel of the admin server.
+// GET reports the current level, PUT with {"level":"debug"} changes it.
+func AdminLogLevel(level *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.admin.level = level
+	}
+}
+
+// OnShutdown registers hooks run after the server has finished the in-flight requests,
+// e.g. to close the database pools. Hooks run in registration order within the shutdown timeout,
+// and their errors are returned from Run.
+//
+//	httpserver.OnShutdown(func(context.Context) error {
+//		pg.Close()
+//		return nil
+//	})
+func OnShutdown(hooks ...ShutdownHook) Option {
+	return func(s *Server) {
+		s.shutdownHooks = append(s.shutdownHooks, hooks...)
+	}
+}
+
+// PreDrainDelay sets how long the server keeps serving after the readiness endpoint starts failing
+// and before it stops accepting connections. It is not part of the shutdown timeout.
+func PreDrainDelay(delay time.Duration) Option {
+	return func(s *Server) {
+		s.preDrainDelay = delay
+	}
+}
+
+// H2C makes the server accept cleartext HTTP/2, with prior knowledge or upgraded from HTTP/1.1,
+// next to plain HTTP/1.1. It is meant for servers without TLS behind a service mesh.
+func H2C() Option {
+	return func(s *Server) {
+		s.h2c = true
+		s.http2Server()
+	}
+}
+
+// HTTP2MaxConcurrentStreams limits the number of concurrent streams of each HTTP/2 connection.
+func HTTP2MaxConcurrentStreams(n uint32) Option {
+	return func(s *Server) {
+		s.http2Server().MaxConcurrentStreams = n
+	}
+}
+
+// HTTP2MaxReadFrameSize sets the largest HTTP/2 frame the server is willing to read,
+// between 16KiB and 16MiB.
+func HTTP2MaxReadFrameSize(n uint32) Option {
+	return func(s *Server) {
+		s.http2Server().MaxReadFrameSize = n
+	}
+}
@@ -0,0 +1,255 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romankravchuk/nix/jwt"
+)
+
+const (
+	minRateLimitCleanup = time.Second
+	maxRateLimitCleanup = time.Minute
+)
+
+// RateLimitResult is the state of a token bucket after a token was taken.
+type RateLimitResult struct {
+	// Allowed reports whether a token was available.
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, if none was.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets of the rate limiter.
+// Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key.
+	Take(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// KeyFunc returns the key of the bucket a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// RateLimit returns a middleware that limits the rate of requests per key with token buckets kept in store.
+// Limited requests get 429 Too Many Requests with Retry-After. Every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. If the store fails, the request
+// is let through. A nil key counts requests by client IP.
+func RateLimit(store RateLimitStore, key KeyFunc) Middleware {
+	if key == nil {
+		key = KeyByIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), key(r))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				_ = WriteError(w, NewError(http.StatusTooManyRequests, "rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds formats d as a whole number of seconds, rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// KeyByIP counts requests by the IP address of the client connection.
+// Proxy headers such as X-Forwarded-For are not trusted.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// KeyByHeader counts requests by the value of the header, or by client IP if it is missing.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+
+		return KeyByIP(r)
+	}
+}
+
+// KeyByJWTSubject counts requests by the "sub" field of the token payload, or by client IP
+// if there is no valid token. The payload stored by Authenticate is used if present,
+// otherwise the bearer token is validated with key.
+func KeyByJWTSubject(key *rsa.PublicKey) KeyFunc {
+	return func(r *http.Request) string {
+		payload, ok := PayloadFromContext(r.Context())
+		if !ok {
+			token := bearerToken(r)
+			if token == "" {
+				return KeyByIP(r)
+			}
+
+			var err error
+			if payload, err = jwt.ValidateToken(token, key); err != nil {
+				return KeyByIP(r)
+			}
+		}
+
+		if m, ok := payload.(map[string]interface{}); ok {
+			if sub, ok := m["sub"]; ok {
+				return fmt.Sprint("sub:", sub)
+			}
+		}
+
+		return KeyByIP(r)
+	}
+}
+
+// bearerToken returns the token of the Authorization: Bearer header, or an empty string.
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimitStore keeps token buckets in memory.
+// Buckets that are full again are removed in the background.
+type MemoryRateLimitStore struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryRateLimitStore creates a store of buckets refilled with rate tokens per second,
+// holding up to burst tokens. The background cleanup stops when ctx is cancelled.
+// It panics if rate or burst is not positive.
+func NewMemoryRateLimitStore(ctx context.Context, rate float64, burst int) *MemoryRateLimitStore {
+	return newMemoryRateLimitStore(ctx, rate, burst, time.Now)
+}
+
+func newMemoryRateLimitStore(ctx context.Context, rate float64, burst int, now func() time.Time) *MemoryRateLimitStore {
+	if !(rate > 0) || burst <= 0 {
+		panic(fmt.Sprintf("httpserver: invalid rate limit %v/s with burst %d", rate, burst))
+	}
+
+	s := &MemoryRateLimitStore{
+		rate:    rate,
+		burst:   burst,
+		now:     now,
+		buckets: make(map[string]*bucket),
+	}
+
+	interval := s.fillDuration(0)
+	if interval < minRateLimitCleanup {
+		interval = minRateLimitCleanup
+	}
+
+	if interval > maxRateLimitCleanup {
+		interval = maxRateLimitCleanup
+	}
+
+	go s.cleanup(ctx, interval)
+
+	return s
+}
+
+// Take takes a token from the bucket of key.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(s.burst), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(s.burst), b.tokens+now.Sub(b.last).Seconds()*s.rate)
+	b.last = now
+
+	res := RateLimitResult{Limit: s.burst}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) / s.rate * float64(time.Second))
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = s.fillDuration(b.tokens)
+
+	return res, nil
+}
+
+// fillDuration returns the time needed to fill a bucket holding the given tokens.
+func (s *MemoryRateLimitStore) fillDuration(tokens float64) time.Duration {
+	return time.Duration((float64(s.burst) - tokens) / s.rate * float64(time.Second))
+}
+
+// cleanup removes the buckets that are full again, since they are equal to new ones.
+func (s *MemoryRateLimitStore) cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+
+			now := s.now()
+			for key, b := range s.buckets {
+				if b.tokens+now.Sub(b.last).Seconds()*s.rate >= float64(s.burst) {
+					delete(s.buckets, key)
+				}
+			}
+
+			s.mu.Unlock()
+		}
+	}
+}
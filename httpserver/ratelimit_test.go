@@ -0,0 +1,84 @@
+package httpserver
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	store := newMemoryRateLimitStore(ctx, 1, 2, clock.Now)
+
+	h := RateLimit(store, KeyByHeader("X-Tenant"))(helloHandler)
+
+	do := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant", tenant)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := do("a")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Reset"))
+
+	require.Equal(t, http.StatusOK, do("a").Code)
+
+	rec = do("a")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	require.Equal(t, http.StatusOK, do("b").Code, "buckets are per key")
+
+	clock.Add(time.Second)
+	require.Equal(t, http.StatusOK, do("a").Code, "bucket is refilled")
+	require.Equal(t, http.StatusTooManyRequests, do("a").Code)
+}
+
+func Test_NewMemoryRateLimitStore_invalid(t *testing.T) {
+	type testCase struct {
+		name  string
+		rate  float64
+		burst int
+	}
+
+	testCases := []testCase{
+		{name: "zero rate", rate: 0, burst: 1},
+		{name: "negative rate", rate: -1, burst: 1},
+		{name: "NaN rate", rate: math.NaN(), burst: 1},
+		{name: "zero burst", rate: 1, burst: 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Panics(t, func() { NewMemoryRateLimitStore(context.Background(), tc.rate, tc.burst) })
+		})
+	}
+}
+
+func Test_KeyByIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	assert.Equal(t, "192.0.2.1", KeyByIP(req))
+}
@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeKey struct{}
+
+// routeHolder carries the route template of a request. It is shared by pointer,
+// so a handler deep in the chain can report the route to the middlewares around it.
+type routeHolder struct {
+	name string
+}
+
+// withRouteHolder returns r carrying a route holder, reusing the one already present.
+func withRouteHolder(r *http.Request) (*http.Request, *routeHolder) {
+	if h, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		return r, h
+	}
+
+	h := &routeHolder{}
+
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, h)), h
+}
+
+// Route returns a handler that tags the requests served by h with the route template name.
+// Metrics and access logs use it as a label instead of the raw path.
+func Route(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, holder := withRouteHolder(r)
+		holder.name = name
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// RouteFromContext returns the route template of the request, or an empty string if it is unknown.
+func RouteFromContext(ctx context.Context) string {
+	if h, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		return h.name
+	}
+
+	return ""
+}
@@ -0,0 +1,309 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type paramsKey struct{}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method  string
+	Pattern string
+}
+
+// Router is a request multiplexer with path parameters and method matching.
+//
+// Patterns are made of segments separated by "/". A segment is either literal,
+// a parameter such as {id} matching a single segment, or a wildcard such as {path...}
+// matching the rest of the path, which must be the last segment:
+//
+//	r := httpserver.NewRouter()
+//	r.Get("/users/{id}", getUser)
+//	r.Get("/static/{path...}", serveStatic)
+//
+//	api := r.Group("/api", httpserver.Authenticate(cfg))
+//	api.Post("/orders", createOrder)
+//
+// Literal segments take precedence over parameters, and parameters over wildcards.
+// A path matching a route registered for other methods gets 405 Method Not Allowed
+// with the Allow header. HEAD requests are served by GET routes if there is no HEAD route.
+// The pattern of the matched route is reported by RouteFromContext, so metrics
+// and access logs are labelled by route template.
+type Router struct {
+	tree   *routeTree
+	prefix string
+	chain  Chain
+
+	// NotFound handles the requests matching no route. It writes 404 Not Found as problem details by default.
+	NotFound http.Handler
+}
+
+// routeTree is shared by a router and its groups.
+type routeTree struct {
+	root   *routeNode
+	routes []RouteInfo
+}
+
+type routeNode struct {
+	literals map[string]*routeNode
+	param    *routeNode
+	wildcard *routeNode
+	// name is the parameter or wildcard name of the node.
+	name string
+
+	pattern  string
+	handlers map[string]http.Handler
+}
+
+// NewRouter creates a new empty router.
+func NewRouter() *Router {
+	return &Router{
+		tree: &routeTree{root: &routeNode{}},
+	}
+}
+
+// Group returns a router registering its routes under prefix, wrapped with the middlewares
+// of rt and mws. The group shares the routes of rt.
+func (rt *Router) Group(prefix string, mws ...Middleware) *Router {
+	return &Router{
+		tree:     rt.tree,
+		prefix:   rt.prefix + strings.TrimSuffix(prefix, "/"),
+		chain:    rt.chain.Append(mws...),
+		NotFound: rt.NotFound,
+	}
+}
+
+// Use adds middlewares to the router. They wrap the routes registered afterwards.
+func (rt *Router) Use(mws ...Middleware) {
+	rt.chain = rt.chain.Append(mws...)
+}
+
+// Handle registers the handler for the method and pattern. It panics if the pattern is invalid
+// or the route is already registered.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	pattern = rt.prefix + pattern
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("httpserver: pattern %q must start with /", pattern))
+	}
+
+	n := rt.tree.root
+	segments := strings.Split(pattern[1:], "/")
+
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}"):
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("httpserver: wildcard in %q must be the last segment", pattern))
+			}
+
+			n = n.child(&n.wildcard, strings.TrimSuffix(seg[1:], "...}"), pattern)
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			n = n.child(&n.param, seg[1:len(seg)-1], pattern)
+		default:
+			if n.literals == nil {
+				n.literals = make(map[string]*routeNode)
+			}
+
+			if n.literals[seg] == nil {
+				n.literals[seg] = &routeNode{}
+			}
+
+			n = n.literals[seg]
+		}
+	}
+
+	method = strings.ToUpper(method)
+	if _, ok := n.handlers[method]; ok {
+		panic(fmt.Sprintf("httpserver: route %s %s is already registered", method, pattern))
+	}
+
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.Handler)
+	}
+
+	n.pattern = pattern
+	n.handlers[method] = rt.chain.Then(h)
+	rt.tree.routes = append(rt.tree.routes, RouteInfo{Method: method, Pattern: pattern})
+}
+
+// child returns the parameter or wildcard child stored in slot, creating it if needed.
+// It panics if the child exists with another name.
+func (n *routeNode) child(slot **routeNode, name, pattern string) *routeNode {
+	if name == "" {
+		panic(fmt.Sprintf("httpserver: empty parameter name in %q", pattern))
+	}
+
+	if *slot == nil {
+		*slot = &routeNode{name: name}
+	}
+
+	if (*slot).name != name {
+		panic(fmt.Sprintf("httpserver: parameter {%s} in %q conflicts with {%s}", name, pattern, (*slot).name))
+	}
+
+	return *slot
+}
+
+// HandleFunc registers the handler function for the method and pattern.
+func (rt *Router) HandleFunc(method, pattern string, fn http.HandlerFunc) {
+	rt.Handle(method, pattern, fn)
+}
+
+// Get registers the handler function for GET requests.
+func (rt *Router) Get(pattern string, fn http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, fn)
+}
+
+// Post registers the handler function for POST requests.
+func (rt *Router) Post(pattern string, fn http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, fn)
+}
+
+// Put registers the handler function for PUT requests.
+func (rt *Router) Put(pattern string, fn http.HandlerFunc) {
+	rt.Handle(http.MethodPut, pattern, fn)
+}
+
+// Patch registers the handler function for PATCH requests.
+func (rt *Router) Patch(pattern string, fn http.HandlerFunc) {
+	rt.Handle(http.MethodPatch, pattern, fn)
+}
+
+// Delete registers the handler function for DELETE requests.
+func (rt *Router) Delete(pattern string, fn http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, fn)
+}
+
+// Routes returns the registered routes in registration order.
+func (rt *Router) Routes() []RouteInfo {
+	return append([]RouteInfo(nil), rt.tree.routes...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	for i, seg := range segments {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	var (
+		params  = make(map[string]string)
+		allowed = make(map[string]struct{})
+	)
+
+	n := rt.tree.root.match(segments, params, r.Method, allowed)
+
+	switch {
+	case n != nil:
+		h := n.handlers[r.Method]
+		if h == nil {
+			h = n.handlers[http.MethodGet]
+		}
+
+		r, holder := withRouteHolder(r)
+		holder.name = n.pattern
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+	case len(allowed) > 0:
+		w.Header().Set("Allow", allowHeader(allowed))
+		_ = WriteError(w, NewError(http.StatusMethodNotAllowed, ""))
+	case rt.NotFound != nil:
+		rt.NotFound.ServeHTTP(w, r)
+	default:
+		_ = WriteError(w, NewError(http.StatusNotFound, ""))
+	}
+}
+
+// match returns the node matching the segments with a handler for the method, filling params.
+// The methods of the nodes matching the segments are added to allowed, so when no node handles
+// the method, allowed holds the methods of all the routes matching the path.
+func (n *routeNode) match(segments []string, params map[string]string, method string, allowed map[string]struct{}) *routeNode {
+	if len(segments) == 0 {
+		if n.handlers == nil {
+			return nil
+		}
+
+		n.addMethods(allowed)
+
+		if n.handles(method) {
+			return n
+		}
+
+		return nil
+	}
+
+	seg, rest := segments[0], segments[1:]
+
+	if child := n.literals[seg]; child != nil {
+		if found := child.match(rest, params, method, allowed); found != nil {
+			return found
+		}
+	}
+
+	if n.param != nil && seg != "" {
+		params[n.param.name] = seg
+		if found := n.param.match(rest, params, method, allowed); found != nil {
+			return found
+		}
+
+		delete(params, n.param.name)
+	}
+
+	if n.wildcard != nil && n.wildcard.handlers != nil {
+		n.wildcard.addMethods(allowed)
+
+		if n.wildcard.handles(method) {
+			params[n.wildcard.name] = strings.Join(segments, "/")
+			return n.wildcard
+		}
+	}
+
+	return nil
+}
+
+// handles reports whether the node has a handler for the method.
+func (n *routeNode) handles(method string) bool {
+	if _, ok := n.handlers[method]; ok {
+		return true
+	}
+
+	_, ok := n.handlers[http.MethodGet]
+
+	return ok && method == http.MethodHead
+}
+
+// addMethods adds the methods of the node to methods, with HEAD if the node handles GET.
+func (n *routeNode) addMethods(methods map[string]struct{}) {
+	for m := range n.handlers {
+		methods[m] = struct{}{}
+	}
+
+	if _, ok := n.handlers[http.MethodGet]; ok {
+		methods[http.MethodHead] = struct{}{}
+	}
+}
+
+// allowHeader returns the value of the Allow header listing the methods.
+func allowHeader(methods map[string]struct{}) string {
+	list := make([]string, 0, len(methods))
+	for m := range methods {
+		list = append(list, m)
+	}
+
+	sort.Strings(list)
+
+	return strings.Join(list, ", ")
+}
+
+// Param returns the value of the path parameter or wildcard matched by the Router, or an empty string.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
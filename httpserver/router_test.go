@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Router(t *testing.T) {
+	t.Parallel()
+
+	echo := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", RouteFromContext(r.Context()))
+			_, _ = w.Write([]byte(name + ":" + Param(r, "id") + Param(r, "path")))
+		}
+	}
+
+	rt := NewRouter()
+	rt.Get("/", echo("root"))
+	rt.Get("/users/{id}", echo("get"))
+	rt.Delete("/users/{id}", echo("delete"))
+	rt.Post("/users/me", echo("me"))
+	rt.Get("/files/{path...}", echo("files"))
+
+	api := rt.Group("/api", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Group", "api")
+			next.ServeHTTP(w, r)
+		})
+	})
+	api.Get("/orders/{id}", echo("order"))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		code   int
+		body   string
+		route  string
+		allow  string
+		group  string
+	}{
+		{name: "root", method: http.MethodGet, target: "/", code: http.StatusOK, body: "root:", route: "/"},
+		{name: "param", method: http.MethodGet, target: "/users/42", code: http.StatusOK, body: "get:42", route: "/users/{id}"},
+		{name: "escaped param", method: http.MethodGet, target: "/users/a%2Fb", code: http.StatusOK, body: "get:a/b", route: "/users/{id}"},
+		{name: "method", method: http.MethodDelete, target: "/users/42", code: http.StatusOK, body: "delete:42", route: "/users/{id}"},
+		{name: "head falls back to get", method: http.MethodHead, target: "/users/42", code: http.StatusOK, route: "/users/{id}"},
+		{name: "literal first", method: http.MethodPost, target: "/users/me", code: http.StatusOK, body: "me:", route: "/users/me"},
+		{name: "param behind literal", method: http.MethodGet, target: "/users/me", code: http.StatusOK, body: "get:me", route: "/users/{id}"},
+		{name: "wildcard", method: http.MethodGet, target: "/files/css/app.css", code: http.StatusOK, body: "files:css/app.css", route: "/files/{path...}"},
+		{name: "group", method: http.MethodGet, target: "/api/orders/7", code: http.StatusOK, body: "order:7", route: "/api/orders/{id}", group: "api"},
+		{name: "not allowed", method: http.MethodPut, target: "/users/42", code: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD"},
+		{name: "allowed by all matching routes", method: http.MethodPut, target: "/users/me", code: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD, POST"},
+		{name: "not found", method: http.MethodGet, target: "/orders/7", code: http.StatusNotFound},
+		{name: "empty param", method: http.MethodGet, target: "/users/", code: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+
+			assert.Equal(t, tc.code, rec.Code)
+			assert.Equal(t, tc.route, rec.Header().Get("X-Route"))
+			assert.Equal(t, tc.allow, rec.Header().Get("Allow"))
+			assert.Equal(t, tc.group, rec.Header().Get("X-Group"))
+
+			if tc.code == http.StatusOK && tc.method != http.MethodHead {
+				assert.Equal(t, tc.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func Test_Router_Routes(t *testing.T) {
+	t.Parallel()
+
+	rt := NewRouter()
+	rt.Get("/a", func(http.ResponseWriter, *http.Request) {})
+	rt.Group("/v1").Post("/b/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	assert.Equal(t, []RouteInfo{
+		{Method: http.MethodGet, Pattern: "/a"},
+		{Method: http.MethodPost, Pattern: "/v1/b/{id}"},
+	}, rt.Routes())
+
+	require.Panics(t, func() { rt.Get("/a", func(http.ResponseWriter, *http.Request) {}) })
+	require.Panics(t, func() { rt.Get("/b/{name}/{path...}/c", func(http.ResponseWriter, *http.Request) {}) })
+	require.Panics(t, func() { rt.Get("/v1/b/{name}", func(http.ResponseWriter, *http.Request) {}) })
+}
@@ -2,12 +2,21 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"log"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/romankravchuk/nix/httpserver/health"
+	"github.com/romankravchuk/nix/httpserver/metrics"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -23,10 +32,43 @@ var defaultErrorLogger = slog.NewLogLogger(slog.NewJSONHandler(os.Stdout, nil),
 type Server struct {
 	server          *http.Server
 	shutdownTimeout time.Duration
+	tls             tlsOptions
+	unix            *unixSocket
+	restartSignals  []os.Signal
+	middlewares     Chain
+	endpoints       map[string]http.Handler
+	health          *health.Health
+	metrics         *metrics.Registry
+	admin           adminOptions
+	shutdownHooks   []ShutdownHook
+	preDrainDelay   time.Duration
+	conns           *connTracker
+	maxBodyBytes    int64
+	h2c             bool
+	http2           *http2.Server
+
+	// draining is closed when the server stops accepting connections, see ShuttingDown.
+	draining chan struct{}
+	// hijacked keeps the connections hijacked from the server, see TrackHijacked.
+	hijacked *hijackTracker
+
+	// handler is the handler wrapped by the middlewares, see SwapHandler.
+	handler *swapHandler
+	// maintenance is set while the server is in maintenance mode, see SetMaintenance.
+	maintenance      atomic.Pointer[maintenanceState]
+	maintenanceAllow []string
+
+	mu            sync.Mutex
+	listener      net.Listener
+	adminListener net.Listener
 }
 
 // New creates a new http server.
 func New(ctx context.Context, handler http.Handler, opts ...Option) *Server {
+	draining := make(chan struct{})
+	hijacked := newHijackTracker()
+	baseCtx := context.WithValue(context.WithValue(ctx, drainingKey{}, draining), hijackedKey{}, hijacked)
+
 	srv := &Server{
 		server: &http.Server{
 			Handler:      handler,
@@ -34,38 +76,165 @@ func New(ctx context.Context, handler http.Handler, opts ...Option) *Server {
 			WriteTimeout: defaultWriteTimeout,
 			Addr:         defaultAddr,
 			BaseContext: func(_ net.Listener) context.Context {
-				return ctx
+				return baseCtx
 			},
 			ErrorLog: defaultErrorLogger,
 		},
 		shutdownTimeout: defaultShutdownTimeout,
+		conns:           newConnTracker(),
+		handler:         newSwapHandler(handler),
+		draining:        draining,
+		hijacked:        hijacked,
+		tls: tlsOptions{
+			minVersion:     tls.VersionTLS12,
+			reloadInterval: defaultCertReloadInterval,
+		},
 	}
 
 	for _, opt := range opts {
 		opt(srv)
 	}
 
+	if srv.metrics != nil && srv.admin.addr == "" {
+		srv.mount("/metrics", srv.metrics)
+	}
+
+	srv.server.ConnState = srv.conns.track
+	mws := srv.middlewares
+	if srv.maxBodyBytes > 0 {
+		mws = NewChain(BodyLimit(srv.maxBodyBytes)).Append(mws...)
+	}
+
+	srv.server.Handler = mws.Then(srv.maintenanceGuard(srv.handler))
+	if len(srv.endpoints) > 0 {
+		srv.server.Handler = &endpointMux{endpoints: srv.endpoints, next: srv.server.Handler}
+	}
+
+	if srv.h2c {
+		srv.server.Handler = h2c.NewHandler(srv.server.Handler, srv.http2)
+	}
+
 	return srv
 }
 
 // Run starts the server. If context is cancelled, the server will be gracefully shutdown.
+// The listeners are bound before Run starts serving, so a bind error is returned immediately.
+// If serving fails later, the server is shut down and the error is returned.
 func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var (
+		reloader *certReloader
+		err      error
+	)
+
+	if s.tls.certFile != "" {
+		s.server.TLSConfig, reloader, err = s.tls.config()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.configureHTTP2(); err != nil {
+		return err
+	}
+
+	ln, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	var (
+		admin   *http.Server
+		adminLn net.Listener
+	)
+
+	if s.admin.addr != "" {
+		admin = s.newAdminServer()
+
+		adminLn, err = s.listenAdmin()
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+	}
+
 	g, gCtx := errgroup.WithContext(ctx)
 	g.Go(func() error {
-		return s.server.ListenAndServe()
+		if reloader != nil {
+			return ignoreServerClosed(s.server.ServeTLS(ln, "", ""))
+		}
+
+		return ignoreServerClosed(s.server.Serve(ln))
 	})
+	if admin != nil {
+		g.Go(func() error {
+			return ignoreServerClosed(admin.Serve(adminLn))
+		})
+	}
+	if reloader != nil {
+		g.Go(func() error {
+			return reloader.watch(gCtx, s.tls.reloadInterval, s.logf)
+		})
+	}
+	if len(s.restartSignals) > 0 {
+		g.Go(func() error {
+			return s.watchRestart(gCtx, ln, adminLn, stop)
+		})
+	}
+
+	var shutdownErr error
 	g.Go(func() error {
 		<-gCtx.Done()
 
-		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
-		defer cancel()
+		shutdownErr = s.shutdown(admin)
 
-		return s.server.Shutdown(ctx)
+		return nil
 	})
 
-	if err := g.Wait(); err != http.ErrServerClosed {
-		return err
+	return errors.Join(g.Wait(), shutdownErr)
+}
+
+// ignoreServerClosed returns nil if err is http.ErrServerClosed, which is expected after a shutdown.
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// mount serves h on path in front of the server handler, bypassing its middlewares.
+func (s *Server) mount(path string, h http.Handler) {
+	if s.endpoints == nil {
+		s.endpoints = make(map[string]http.Handler)
+	}
+
+	s.endpoints[path] = h
+}
+
+// endpointMux serves the built-in endpoints of the server and passes other requests to next.
+type endpointMux struct {
+	endpoints map[string]http.Handler
+	next      http.Handler
+}
+
+func (m *endpointMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m.endpoints[r.URL.Path]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	m.next.ServeHTTP(w, r)
+}
+
+// logf writes a message to the error logger of the server.
+func (s *Server) logf(format string, args ...any) {
+	if s.server.ErrorLog != nil {
+		s.server.ErrorLog.Printf(format, args...)
+		return
 	}
 
-	return nil
+	log.Printf(format, args...)
 }
@@ -0,0 +1,166 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var helloHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	_, _ = io.WriteString(w, "hello")
+})
+
+// startServer runs srv in the background and returns a function that stops it and returns the Run error.
+func startServer(t *testing.T, srv *Server) func() error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- srv.Run(ctx)
+	}()
+
+	return func() error {
+		cancel()
+		return <-errCh
+	}
+}
+
+func Test_Server_Listener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := New(context.Background(), helloHandler, Listener(ln))
+	require.Equal(t, ln.Addr(), srv.Addr())
+
+	stop := startServer(t, srv)
+
+	resp, err := http.Get("http://" + srv.Addr().String())
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	require.NoError(t, stop())
+}
+
+func Test_Server_UnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported")
+	}
+
+	path := filepath.Join(t.TempDir(), "nix.sock")
+	srv := New(context.Background(), helloHandler, UnixSocket(path, 0o600))
+	stop := startServer(t, srv)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://unix/")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, stop())
+}
+
+func Test_Server_OnShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		order   []string
+		hookErr = errors.New("close failed")
+	)
+
+	srv := New(context.Background(), helloHandler,
+		Listener(ln),
+		PreDrainDelay(10*time.Millisecond),
+		OnShutdown(
+			func(context.Context) error {
+				order = append(order, "workers")
+				return nil
+			},
+			func(context.Context) error {
+				order = append(order, "postgres")
+				return hookErr
+			},
+		),
+		OnShutdown(func(context.Context) error {
+			order = append(order, "redis")
+			return nil
+		}),
+	)
+
+	stop := startServer(t, srv)
+
+	err = stop()
+	require.ErrorIs(t, err, hookErr)
+	assert.Equal(t, []string{"workers", "postgres", "redis"}, order)
+}
+
+func Test_Server_BindError(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	srv := New(context.Background(), helloHandler, Port(port))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Run(context.Background())
+	}()
+
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not fail on a bind error")
+	}
+}
+
+func Test_Server_ConnStats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := New(context.Background(), helloHandler, Listener(ln))
+	stop := startServer(t, srv)
+
+	resp, err := http.Get("http://" + srv.Addr().String())
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	require.Eventually(t, func() bool {
+		return srv.ConnStats() == ConnStats{Idle: 1}
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1, srv.CloseIdleConns())
+	assert.Equal(t, ConnStats{}, srv.ConnStats())
+
+	require.NoError(t, stop())
+}
@@ -0,0 +1,76 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type drainingKey struct{}
+
+// ShuttingDown returns a channel that is closed when the server serving the request
+// starts draining. Long-lived handlers such as streams should return when it is closed,
+// so the graceful shutdown does not wait for them until the timeout.
+// The channel is never closed for requests not served by a Server.
+func ShuttingDown(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return ch
+}
+
+// ShutdownHook is a function run during the graceful shutdown, after in-flight requests are finished.
+type ShutdownHook func(ctx context.Context) error
+
+// shutdown drains the server in phases:
+//
+//  1. the readiness endpoint starts failing;
+//  2. the server waits for the pre-drain delay, so load balancers notice it;
+//  3. the servers stop accepting connections, close the idle keep-alive connections,
+//     signal the long-lived requests through ShuttingDown and wait for in-flight requests,
+//     while the hijacked connections are asked to close, see TrackHijacked;
+//  4. the shutdown hooks run in registration order.
+//
+// The phases 3 and 4 share the shutdown timeout. All the errors are returned joined.
+func (s *Server) shutdown(admin *http.Server) error {
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+
+	if s.preDrainDelay > 0 {
+		time.Sleep(s.preDrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	s.server.SetKeepAlivesEnabled(false)
+	s.conns.closeIdle()
+	close(s.draining)
+
+	hijackedErr := make(chan error, 1)
+	go func() {
+		hijackedErr <- s.hijacked.shutdown(ctx)
+	}()
+
+	errs := []error{s.shutdownServers(ctx, admin), <-hijackedErr}
+
+	for _, hook := range s.shutdownHooks {
+		errs = append(errs, hook(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// shutdownServers gracefully shuts down the server and the admin server, if any, concurrently.
+func (s *Server) shutdownServers(ctx context.Context, admin *http.Server) error {
+	if admin == nil {
+		return s.server.Shutdown(ctx)
+	}
+
+	adminErr := make(chan error, 1)
+	go func() {
+		adminErr <- admin.Shutdown(ctx)
+	}()
+
+	return errors.Join(s.server.Shutdown(ctx), <-adminErr)
+}
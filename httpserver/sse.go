@@ -0,0 +1,228 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSSEHeartbeat    = 15 * time.Second
+	defaultSSEWriteTimeout = 10 * time.Second
+)
+
+// ErrSSEClosed is the error returned when sending to a closed event stream.
+var ErrSSEClosed = errors.New("event stream closed")
+
+// newlineReplacer removes line breaks from the single-line fields of an event.
+var newlineReplacer = strings.NewReplacer("\r\n", "", "\r", "", "\n", "")
+
+// dataNewlineReplacer normalizes the line breaks of the data field, since CR, LF and CRLF all end a line.
+var dataNewlineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is the event ID, sent back by the client in Last-Event-ID when it reconnects.
+	ID string
+	// Event is the event type, "message" on the client if empty.
+	Event string
+	// Data is the payload of the event. It may span multiple lines.
+	Data string
+	// Retry is the reconnection delay the client should use from now on.
+	Retry time.Duration
+}
+
+// SSEConfig configures an SSEWriter.
+type SSEConfig struct {
+	// Heartbeat is the interval of the comments sent to keep the connection open, 15s by default.
+	// A negative interval disables them.
+	Heartbeat time.Duration
+	// WriteTimeout limits every single write, 10s by default. It replaces the write timeout
+	// of the server, which would otherwise end the stream.
+	WriteTimeout time.Duration
+	// Retry is the reconnection delay sent to the client when the stream opens.
+	Retry time.Duration
+}
+
+// SSEWriter writes server-sent events to a response.
+//
+// The stream is done when the client disconnects, a write fails, the server starts
+// its graceful shutdown or Close is called. The handler should return then:
+//
+//	sse, err := httpserver.NewSSEWriter(w, r, httpserver.SSEConfig{})
+//	if err != nil {
+//		return
+//	}
+//	defer sse.Close()
+//
+//	for {
+//		select {
+//		case <-sse.Done():
+//			return
+//		case update := <-updates:
+//			_ = sse.Send(httpserver.Event{ID: update.ID, Data: update.JSON})
+//		}
+//	}
+type SSEWriter struct {
+	w            http.ResponseWriter
+	rc           *http.ResponseController
+	writeTimeout time.Duration
+	lastEventID  string
+
+	mu     sync.Mutex
+	closed bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSSEWriter starts an event stream on w. It fails if the response can not be flushed.
+func NewSSEWriter(w http.ResponseWriter, r *http.Request, cfg SSEConfig) (*SSEWriter, error) {
+	if cfg.Heartbeat == 0 {
+		cfg.Heartbeat = defaultSSEHeartbeat
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultSSEWriteTimeout
+	}
+
+	s := &SSEWriter{
+		w:            w,
+		rc:           http.NewResponseController(w),
+		writeTimeout: cfg.WriteTimeout,
+		lastEventID:  r.Header.Get("Last-Event-ID"),
+		done:         make(chan struct{}),
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+
+	_ = s.rc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	w.WriteHeader(http.StatusOK)
+
+	var opening string
+	if cfg.Retry > 0 {
+		opening = "retry: " + strconv.FormatInt(cfg.Retry.Milliseconds(), 10) + "\n\n"
+	}
+
+	if err := s.write(opening); err != nil {
+		return nil, err
+	}
+
+	go s.watch(r, cfg.Heartbeat)
+
+	return s, nil
+}
+
+// LastEventID returns the ID of the last event received by the client before it reconnected,
+// so the stream can resume from there. It is empty for a new stream.
+func (s *SSEWriter) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel closed when the stream is done.
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes the event and flushes it to the client.
+func (s *SSEWriter) Send(ev Event) error {
+	var b strings.Builder
+
+	if ev.ID != "" {
+		b.WriteString("id: " + newlineReplacer.Replace(ev.ID) + "\n")
+	}
+
+	if ev.Event != "" {
+		b.WriteString("event: " + newlineReplacer.Replace(ev.Event) + "\n")
+	}
+
+	if ev.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data := dataNewlineReplacer.Replace(ev.Data)
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Close ends the stream. The handler must return afterwards for the response to complete.
+func (s *SSEWriter) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeLocked()
+}
+
+// write writes and flushes chunk. A failed write closes the stream.
+func (s *SSEWriter) write(chunk string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSSEClosed
+	}
+
+	_ = s.rc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+
+	if chunk != "" {
+		if _, err := s.w.Write([]byte(chunk)); err != nil {
+			s.closeLocked()
+			return err
+		}
+	}
+
+	if err := s.rc.Flush(); err != nil {
+		s.closeLocked()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SSEWriter) closeLocked() {
+	s.closed = true
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// watch sends the heartbeats and closes the stream when the client disconnects
+// or the server starts draining.
+func (s *SSEWriter) watch(r *http.Request, heartbeat time.Duration) {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-r.Context().Done():
+			s.Close()
+			return
+		case <-ShuttingDown(r.Context()):
+			s.Close()
+			return
+		case <-tick:
+			if err := s.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
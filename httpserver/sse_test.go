@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvent reads the lines of the next event, up to the blank line ending it.
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+
+	var lines []string
+
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+
+		lines = append(lines, line)
+	}
+}
+
+func Test_SSEWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		lastEventID = make(chan string, 1)
+		handlerDone = make(chan struct{})
+	)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+
+		sse, err := NewSSEWriter(w, r, SSEConfig{Heartbeat: 20 * time.Millisecond, Retry: 3 * time.Second})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer sse.Close()
+
+		lastEventID <- sse.LastEventID()
+
+		assert.NoError(t, sse.Send(Event{ID: "7", Event: "update", Data: "line 1\nline 2"}))
+
+		<-sse.Done()
+	})
+
+	srv := New(context.Background(), h, Listener(ln), WriteTimeout(50*time.Millisecond))
+	stop := startServer(t, srv)
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+srv.Addr().String(), nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "6")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "6", <-lastEventID)
+
+	body := bufio.NewReader(resp.Body)
+	assert.Equal(t, []string{"retry: 3000"}, readEvent(t, body))
+	assert.Equal(t, []string{"id: 7", "event: update", "data: line 1", "data: line 2"}, readEvent(t, body))
+
+	// Heartbeats keep the stream open past the write timeout of the server.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{": heartbeat"}, readEvent(t, body))
+
+	start := time.Now()
+	require.NoError(t, stop())
+	assert.Less(t, time.Since(start), defaultShutdownTimeout)
+
+	select {
+	case <-handlerDone:
+	case <-time.After(time.Second):
+		t.Fatal("stream not closed on shutdown")
+	}
+}
+
+func Test_SSEWriter_Send_lineBreaks(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	sse, err := NewSSEWriter(rec, httptest.NewRequest(http.MethodGet, "/", nil), SSEConfig{Heartbeat: -1})
+	require.NoError(t, err)
+	defer sse.Close()
+
+	require.NoError(t, sse.Send(Event{ID: "1\r2", Event: "a\rb", Data: "x\revent: evil\r\nid: 9\ny"}))
+
+	assert.Equal(t, "id: 12\nevent: ab\ndata: x\ndata: event: evil\ndata: id: 9\ndata: y\n\n", rec.Body.String())
+}
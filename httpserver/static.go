@@ -0,0 +1,254 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "no-cache"
+
+	minFingerprintLength = 8
+)
+
+// StaticConfig configures Static.
+type StaticConfig struct {
+	// Index is the file served for directories and SPA routes. index.html by default.
+	Index string
+	// SPA enables serving the root index for paths matching no file, so client-side routes work.
+	// Paths with an extension, such as missing assets, still get 404 Not Found.
+	SPA bool
+	// Immutable reports whether the file name holds a content hash, so the file can be cached forever.
+	// By default, a name such as app.3f9c2b1a.js or index-B2x9QkLm.js is considered fingerprinted.
+	Immutable func(name string) bool
+}
+
+// staticFile is a file served by Static.
+type staticFile struct {
+	name        string
+	etag        string
+	contentType string
+	modTime     time.Time
+	immutable   bool
+	// gz is the precompressed sibling of the file, if any.
+	gz *staticFile
+}
+
+// Static returns a handler serving the files of fsys, typically an embed.FS.
+//
+// ETags are computed from the file contents when the handler is created, so conditional requests
+// with If-None-Match get 304 Not Modified. Fingerprinted files are served with an immutable
+// Cache-Control, others must be revalidated. A file.gz sibling is served instead of the file
+// to clients accepting gzip. Only GET and HEAD requests are allowed.
+func Static(fsys fs.FS, cfg StaticConfig) (http.Handler, error) {
+	if cfg.Index == "" {
+		cfg.Index = "index.html"
+	}
+
+	if cfg.Immutable == nil {
+		cfg.Immutable = fingerprinted
+	}
+
+	files := make(map[string]*staticFile)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		f, err := newStaticFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		files[name] = f
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, f := range files {
+		base := strings.TrimSuffix(name, ".gz")
+		f.immutable = cfg.Immutable(base)
+
+		if orig, ok := files[base]; ok && orig != f {
+			orig.gz = f
+		}
+	}
+
+	return &staticHandler{fsys: fsys, files: files, cfg: cfg}, nil
+}
+
+// newStaticFile hashes the file and detects its content type.
+func newStaticFile(fsys fs.FS, name string) (*staticFile, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	return &staticFile{
+		name:        name,
+		etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		contentType: contentType,
+		modTime:     info.ModTime(),
+	}, nil
+}
+
+// fingerprinted reports whether a part of the file name, other than the first, looks like a content hash:
+// at least 8 letters, digits or underscores including a digit.
+func fingerprinted(name string) bool {
+	parts := strings.FieldsFunc(path.Base(name), func(r rune) bool { return r == '.' || r == '-' })
+	if len(parts) < 3 {
+		return false
+	}
+
+	// The first part is the name, the last one is the extension.
+	for _, part := range parts[1 : len(parts)-1] {
+		if len(part) >= minFingerprintLength && isHashLike(part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHashLike reports whether s consists of letters, digits and underscores and contains a digit.
+func isHashLike(s string) bool {
+	digit := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		default:
+			return false
+		}
+	}
+
+	return digit
+}
+
+type staticHandler struct {
+	fsys  fs.FS
+	files map[string]*staticFile
+	cfg   StaticConfig
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		_ = WriteError(w, NewError(http.StatusMethodNotAllowed, ""))
+		return
+	}
+
+	f := h.lookup(r.URL.Path)
+	if f == nil {
+		_ = WriteError(w, NewError(http.StatusNotFound, ""))
+		return
+	}
+
+	var (
+		hdr = w.Header()
+		// The compressed sibling is served with the content type of the original.
+		contentType = f.contentType
+	)
+
+	if f.gz != nil {
+		hdr.Add("Vary", "Accept-Encoding")
+
+		if negotiateEncoding(r.Header.Get("Accept-Encoding")) == "gzip" {
+			hdr.Set("Content-Encoding", "gzip")
+			f = f.gz
+		}
+	}
+
+	if f.immutable {
+		hdr.Set("Cache-Control", immutableCacheControl)
+	} else {
+		hdr.Set("Cache-Control", revalidateCacheControl)
+	}
+
+	hdr.Set("ETag", f.etag)
+	hdr.Set("Content-Type", contentType)
+
+	content, err := h.open(f.name)
+	if err != nil {
+		hdr.Del("Content-Encoding")
+		_ = WriteError(w, NewError(http.StatusInternalServerError, ""))
+		return
+	}
+
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	http.ServeContent(w, r, f.name, f.modTime, content)
+}
+
+// lookup returns the file served for the URL path: the file itself, the index of the directory,
+// or the root index for SPA routes.
+func (h *staticHandler) lookup(urlPath string) *staticFile {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+
+	if f, ok := h.files[name]; ok && name != "" {
+		return f
+	}
+
+	if f, ok := h.files[path.Join(name, h.cfg.Index)]; ok {
+		return f
+	}
+
+	if h.cfg.SPA && path.Ext(name) == "" {
+		return h.files[h.cfg.Index]
+	}
+
+	return nil
+}
+
+// open returns the content of the file as an io.ReadSeeker, reading it in memory
+// if the file system does not provide one.
+func (h *staticHandler) open(name string) (io.ReadSeeker, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
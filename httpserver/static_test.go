@@ -0,0 +1,130 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Static(t *testing.T) {
+	t.Parallel()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte("console.log('app')"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	fsys := fstest.MapFS{
+		"index.html":                {Data: []byte("<html>app</html>")},
+		"assets/app.3f9c2b1a.js":    {Data: []byte("console.log('app')")},
+		"assets/app.3f9c2b1a.js.gz": {Data: gz.Bytes()},
+		"docs/index.html":           {Data: []byte("<html>docs</html>")},
+	}
+
+	h, err := Static(fsys, StaticConfig{SPA: true})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		encoding     string
+		code         int
+		body         string
+		cacheControl string
+		contentType  string
+		gzip         bool
+	}{
+		{name: "index", target: "/", code: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{name: "asset", target: "/assets/app.3f9c2b1a.js", code: http.StatusOK, body: "console.log('app')", cacheControl: immutableCacheControl},
+		{name: "precompressed", target: "/assets/app.3f9c2b1a.js", encoding: "gzip", code: http.StatusOK, body: gz.String(), cacheControl: immutableCacheControl, contentType: "text/javascript", gzip: true},
+		{name: "compressed file", target: "/assets/app.3f9c2b1a.js.gz", encoding: "gzip", code: http.StatusOK, body: gz.String(), cacheControl: immutableCacheControl, contentType: "gzip"},
+		{name: "directory index", target: "/docs/", code: http.StatusOK, body: "<html>docs</html>", cacheControl: "no-cache"},
+		{name: "spa route", target: "/orders/42", code: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{name: "missing asset", target: "/assets/missing.js", code: http.StatusNotFound},
+		{name: "method", method: http.MethodPost, target: "/", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tc.target, nil)
+			if tc.encoding != "" {
+				req.Header.Set("Accept-Encoding", tc.encoding)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.code, rec.Code)
+
+			if tc.code != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tc.body, rec.Body.String())
+			assert.Equal(t, tc.cacheControl, rec.Header().Get("Cache-Control"))
+			assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+			if tc.contentType != "" {
+				assert.Contains(t, rec.Header().Get("Content-Type"), tc.contentType)
+			}
+
+			if tc.gzip {
+				assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func Test_Static_notModified(t *testing.T) {
+	t.Parallel()
+
+	h, err := Static(fstest.MapFS{"index.html": {Data: []byte("<html>app</html>")}}, StaticConfig{})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func Test_fingerprinted(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"app.3f9c2b1a.js":          true,
+		"assets/index-B2x9QkLm.js": true,
+		"app.js":                   false,
+		"bootstrap.min.css":        false,
+		"favicon-original.png":     false,
+	}
+
+	for name, want := range tests {
+		assert.Equal(t, want, fingerprinted(name), name)
+	}
+}
@@ -0,0 +1,210 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout returns a middleware that limits the duration of the next handler.
+// When the timeout expires, the request context is cancelled and the client gets
+// 503 Service Unavailable as problem details, see Error. The response is buffered
+// until the handler returns, so Timeout must not wrap streaming handlers.
+//
+// The route set by the next handler, see Route, is reported to the outer middlewares
+// only if the handler finishes in time. If the request context is cancelled before the
+// timeout, the response of the handler is written once it returns.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			// The handler may outlive the response, so it gets its own copy of the route holder.
+			holder, _ := r.Context().Value(routeKey{}).(*routeHolder)
+			inner := &routeHolder{}
+			if holder != nil {
+				inner.name = holder.name
+			}
+
+			ctx = context.WithValue(ctx, routeKey{}, inner)
+
+			// Only the own deadline is a timeout, not a cancelled or expired request context.
+			expired := func() bool {
+				return errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Context().Err() == nil
+			}
+
+			var (
+				tw     = &timeoutWriter{header: make(http.Header)}
+				done   = make(chan struct{})
+				panics = make(chan any, 1)
+			)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panics <- p
+					}
+				}()
+
+				next.ServeHTTP(tw, r.WithContext(ctx))
+
+				// A handler returning because the context is done has not finished in time.
+				tw.mu.Lock()
+				tw.finished = !expired()
+				tw.mu.Unlock()
+
+				close(done)
+			}()
+
+			select {
+			case p := <-panics:
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+				if !expired() {
+					select {
+					case p := <-panics:
+						panic(p)
+					case <-done:
+					}
+				}
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			// Both cases may be ready when the handler finishes at the deadline,
+			// so whether it finished in time is checked before the timeout response is written.
+			if !tw.finished {
+				tw.timedOut = true
+				_ = WriteError(w, NewError(http.StatusServiceUnavailable, "request timeout"))
+
+				return
+			}
+
+			if holder != nil {
+				holder.name = inner.name
+			}
+
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+
+			w.WriteHeader(tw.code)
+			_, _ = w.Write(tw.buf.Bytes())
+		})
+	}
+}
+
+// timeoutWriter buffers the response of a handler run by Timeout.
+type timeoutWriter struct {
+	header http.Header
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	code     int
+	finished bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+
+	tw.code = code
+}
+
+// OverrideTimeouts returns a middleware that replaces the read and write timeouts of the server
+// for the next handler, counting from the start of the request. A zero timeout disables the deadline,
+// which suits long-running routes such as uploads and streams.
+func OverrideTimeouts(read, write time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				rc  = http.NewResponseController(w)
+				now = time.Now()
+			)
+
+			_ = rc.SetReadDeadline(deadline(now, read))
+			_ = rc.SetWriteDeadline(deadline(now, write))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NoTimeout returns a middleware that disables the read and write timeouts of the server for the next handler.
+func NoTimeout() Middleware {
+	return OverrideTimeouts(0, 0)
+}
+
+// deadline returns now plus timeout, or the zero time, meaning no deadline, if timeout is zero.
+func deadline(now time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(timeout)
+}
+
+// limitedBody is a request body limited by BodyLimit. It keeps the original body,
+// so a nested BodyLimit can replace the limit instead of only lowering it.
+type limitedBody struct {
+	io.ReadCloser
+	orig io.ReadCloser
+}
+
+// BodyLimit returns a middleware that limits the size of the request body to n bytes.
+// Reading past the limit fails with *http.MaxBytesError. It replaces the limit set by
+// the MaxBodyBytes option or an outer BodyLimit, and n <= 0 removes it.
+func BodyLimit(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := r.Body
+			if lb, ok := body.(*limitedBody); ok {
+				body = lb.orig
+			}
+
+			if n > 0 {
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, n), orig: body}
+			} else {
+				r.Body = body
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Timeout(t *testing.T) {
+	type testCase struct {
+		name         string
+		delay        time.Duration
+		expectedCode int
+		expectedBody string
+	}
+
+	testCases := []testCase{
+		{
+			name:         "in time",
+			expectedCode: http.StatusAccepted,
+			expectedBody: "done",
+		},
+		{
+			name:         "timed out",
+			delay:        time.Second,
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: `{"title":"Service Unavailable","status":503,"detail":"request timeout"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tc.delay):
+				case <-r.Context().Done():
+					return
+				}
+
+				w.Header().Set("X-Handler", "yes")
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("done"))
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func Test_Timeout_cancelled(t *testing.T) {
+	t.Parallel()
+
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("cancelled"))
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "cancelled", rec.Body.String())
+}
+
+func Test_Timeout_route(t *testing.T) {
+	type testCase struct {
+		name          string
+		delay         time.Duration
+		expectedRoute string
+	}
+
+	testCases := []testCase{
+		{name: "in time", expectedRoute: "/orders/{id}"},
+		{name: "timed out", delay: time.Second},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			routed := make(chan struct{})
+			route := Route("/orders/{id}", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				close(routed)
+			}))
+
+			h := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tc.delay):
+				case <-r.Context().Done():
+					// The handler keeps going after the timeout response.
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				route.ServeHTTP(w, r)
+			}))
+
+			req, _ := withRouteHolder(httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			<-routed
+
+			assert.Equal(t, tc.expectedRoute, RouteFromContext(req.Context()))
+		})
+	}
+}
+
+func Test_BodyLimit(t *testing.T) {
+	type testCase struct {
+		name        string
+		chain       Chain
+		body        string
+		expectedErr bool
+	}
+
+	testCases := []testCase{
+		{
+			name:  "within limit",
+			chain: NewChain(BodyLimit(8)),
+			body:  "12345678",
+		},
+		{
+			name:        "over limit",
+			chain:       NewChain(BodyLimit(8)),
+			body:        "123456789",
+			expectedErr: true,
+		},
+		{
+			name:  "raised by route",
+			chain: NewChain(BodyLimit(4), BodyLimit(16)),
+			body:  "123456789",
+		},
+		{
+			name:        "lowered by route",
+			chain:       NewChain(BodyLimit(16), BodyLimit(4)),
+			body:        "123456789",
+			expectedErr: true,
+		},
+		{
+			name:  "removed by route",
+			chain: NewChain(BodyLimit(4), BodyLimit(0)),
+			body:  "123456789",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var readErr error
+			h := tc.chain.ThenFunc(func(_ http.ResponseWriter, r *http.Request) {
+				_, readErr = io.ReadAll(r.Body)
+			})
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)))
+
+			if tc.expectedErr {
+				var maxErr *http.MaxBytesError
+				require.True(t, errors.As(readErr, &maxErr))
+			} else {
+				require.NoError(t, readErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultCertReloadInterval = 10 * time.Second
+
+// ErrInvalidClientCA is the error returned when the client CA file holds no certificates.
+var ErrInvalidClientCA = errors.New("no certificates found in client CA file")
+
+// tlsOptions holds the TLS settings collected by the TLS options.
+type tlsOptions struct {
+	certFile       string
+	keyFile        string
+	clientCAFile   string
+	minVersion     uint16
+	cipherSuites   []uint16
+	reloadInterval time.Duration
+}
+
+// config builds the tls.Config of the server and the reloader that serves its certificate.
+func (o *tlsOptions) config() (*tls.Config, *certReloader, error) {
+	reloader, err := newCertReloader(o.certFile, o.keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     o.minVersion,
+		CipherSuites:   o.cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if o.clientCAFile != "" {
+		pem, err := os.ReadFile(o.clientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, ErrInvalidClientCA
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, reloader, nil
+}
+
+// certReloader keeps the server certificate in sync with the files on disk.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the currently loaded certificate.
+// It is meant to be used as tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// reload loads the key pair again if one of the files has been modified.
+// It reports whether the certificate has been replaced.
+func (r *certReloader) reload() (bool, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false, err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil &&
+		certInfo.ModTime().Equal(r.certMod) &&
+		keyInfo.ModTime().Equal(r.keyMod)
+	r.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+
+	return true, nil
+}
+
+// watch polls the certificate files until the context is cancelled.
+// A failed reload keeps the previous certificate in use.
+// A non-positive interval disables reloading.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration, logf func(string, ...any)) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				logf("httpserver: reload certificate %s: %v", r.certFile, err)
+				continue
+			}
+
+			if reloaded {
+				logf("httpserver: certificate %s reloaded", r.certFile)
+			}
+		}
+	}
+}
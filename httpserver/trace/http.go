@@ -0,0 +1,57 @@
+package trace
+
+import (
+	"net/http"
+)
+
+// Middleware returns a middleware that continues the trace of the request described by
+// the traceparent and tracestate headers, or starts a new one when they are absent or malformed.
+// The span of the request is stored in the context and its traceparent is set on the response.
+//
+// It should wrap the logging middlewares, so their records carry the trace.
+func Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent, _ := Parse(r.Header.Get(TraceparentHeader), r.Header.Get(TracestateHeader))
+			sc := NewSpan(parent)
+
+			w.Header().Set(TraceparentHeader, sc.Traceparent())
+
+			next.ServeHTTP(w, r.WithContext(ContextWithSpan(r.Context(), sc)))
+		})
+	}
+}
+
+// Transport returns an http.RoundTripper that propagates the span stored in the request
+// context with the traceparent and tracestate headers. Requests without a span start a new trace.
+// If next is nil, http.DefaultTransport is used.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripper{next: next}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	sc, ok := SpanFromContext(r.Context())
+	if !ok || !sc.IsValid() {
+		sc = NewSpan(SpanContext{})
+	}
+
+	// A RoundTripper must not modify the request.
+	r = r.Clone(r.Context())
+	r.Header.Set(TraceparentHeader, sc.Traceparent())
+
+	if sc.State != "" {
+		r.Header.Set(TracestateHeader, sc.State)
+	} else {
+		r.Header.Del(TracestateHeader)
+	}
+
+	return rt.next.RoundTrip(r)
+}
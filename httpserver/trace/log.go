@@ -0,0 +1,45 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler is a slog.Handler adding the trace_id and span_id attributes
+// of the span stored in the context to every record.
+type LogHandler struct {
+	next slog.Handler
+}
+
+// NewLogHandler returns a handler passing the records to next with the trace attributes added.
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{next: next}
+}
+
+// Enabled reports whether the next handler handles records at the level.
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the trace attributes to the record and passes it to the next handler.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc, ok := SpanFromContext(ctx); ok && sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID.String()),
+			slog.String("span_id", sc.SpanID.String()),
+		)
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler whose next handler has the attributes.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler whose next handler has the group.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,184 @@
+// Package trace implements W3C Trace Context propagation, see https://www.w3.org/TR/trace-context/.
+//
+// Middleware continues the trace of incoming requests, Transport propagates it
+// on outgoing calls and NewLogHandler adds the trace and span IDs to log records.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	// TraceparentHeader is the header carrying the trace ID, the parent span ID and the trace flags.
+	TraceparentHeader = "traceparent"
+	// TracestateHeader is the header carrying vendor-specific trace data.
+	TracestateHeader = "tracestate"
+
+	// FlagSampled is the trace flag set when the caller may have recorded the trace.
+	FlagSampled byte = 0x01
+
+	traceparentVersion = "00"
+	traceparentLength  = 55
+)
+
+// ErrInvalidTraceparent is the error returned when the traceparent header is malformed.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// ParentID is the ID of the remote span the span continues, if any.
+	ParentID SpanID
+	Flags    byte
+	// State is the value of the tracestate header, passed on unchanged.
+	State string
+}
+
+// IsValid reports whether both the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Sampled reports whether the sampled flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent returns the value of the traceparent header naming the span as the parent.
+func (sc SpanContext) Traceparent() string {
+	var b strings.Builder
+
+	b.Grow(traceparentLength)
+	b.WriteString(traceparentVersion)
+	b.WriteByte('-')
+	b.WriteString(sc.TraceID.String())
+	b.WriteByte('-')
+	b.WriteString(sc.SpanID.String())
+	b.WriteByte('-')
+	b.WriteString(hex.EncodeToString([]byte{sc.Flags}))
+
+	return b.String()
+}
+
+// Parse parses the traceparent and tracestate headers. The span ID of the returned
+// span context is the parent ID of the header. Versions other than 00 are parsed
+// as 00, ignoring any trailing fields, as the specification requires.
+func Parse(traceparent, tracestate string) (SpanContext, error) {
+	if len(traceparent) < traceparentLength ||
+		(len(traceparent) > traceparentLength && traceparent[traceparentLength] != '-') {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	parts := strings.Split(traceparent[:traceparentLength], "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 ||
+		parts[0] == "ff" || !lowerHex(traceparent[:traceparentLength]) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	if parts[0] == traceparentVersion && len(traceparent) != traceparentLength {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var (
+		sc    = SpanContext{State: tracestate}
+		flags [1]byte
+	)
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+// lowerHex reports whether s consists of lowercase hex digits and dashes.
+func lowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewSpan returns a child span of parent. A new sampled trace is started if parent is invalid.
+func NewSpan(parent SpanContext) SpanContext {
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		Flags:   parent.Flags,
+		State:   parent.State,
+	}
+
+	if parent.IsValid() {
+		sc.ParentID = parent.SpanID
+	} else {
+		sc = SpanContext{Flags: FlagSampled}
+		for !sc.TraceID.IsValid() {
+			_, _ = rand.Read(sc.TraceID[:])
+		}
+	}
+
+	for !sc.SpanID.IsValid() {
+		_, _ = rand.Read(sc.SpanID[:])
+	}
+
+	return sc
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying the span context.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanFromContext returns the span context stored in ctx, or false if there is none.
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok
+}
@@ -0,0 +1,133 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		err    bool
+	}{
+		{name: "valid", header: validTraceparent},
+		{name: "future version", header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "trailing data", header: validTraceparent + "-extra", err: true},
+		{name: "invalid version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", err: true},
+		{name: "uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", err: true},
+		{name: "zero trace id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", err: true},
+		{name: "zero span id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", err: true},
+		{name: "misplaced dash", header: "00-4bf92f3577b34da6a3ce929d0e0e47360-0f067aa0ba902b7-01", err: true},
+		{name: "empty", header: "", err: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sc, err := Parse(tc.header, "k=v")
+			if tc.err {
+				require.ErrorIs(t, err, ErrInvalidTraceparent)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+			assert.True(t, sc.Sampled())
+			assert.Equal(t, "k=v", sc.State)
+		})
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	t.Parallel()
+
+	var got SpanContext
+
+	h := Middleware()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, _ = SpanFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, validTraceparent)
+	req.Header.Set(TracestateHeader, "k=v")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", got.ParentID.String())
+	assert.NotEqual(t, got.ParentID, got.SpanID)
+	assert.Equal(t, "k=v", got.State)
+	assert.Equal(t, got.Traceparent(), rec.Header().Get(TraceparentHeader))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, got.IsValid())
+	assert.False(t, got.ParentID.IsValid())
+	assert.True(t, got.Sampled())
+}
+
+func Test_Transport(t *testing.T) {
+	t.Parallel()
+
+	var header http.Header
+
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	sc, err := Parse(validTraceparent, "k=v")
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: Transport(nil)}
+
+	req, err := http.NewRequestWithContext(ContextWithSpan(context.Background(), sc), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, validTraceparent, header.Get(TraceparentHeader))
+	assert.Equal(t, "k=v", header.Get(TracestateHeader))
+	assert.Empty(t, req.Header.Get(TraceparentHeader))
+}
+
+func Test_LogHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("app", "nix")
+
+	sc, err := Parse(validTraceparent, "")
+	require.NoError(t, err)
+
+	logger.InfoContext(ContextWithSpan(context.Background(), sc), "hello")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+	assert.Equal(t, "nix", record["app"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+}
@@ -0,0 +1,441 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Close codes, see RFC 6455, section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	// CloseNoStatus is reported when the close frame has no code. It is never sent.
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload = 125
+	maxHeaderLength   = 10
+)
+
+var (
+	// ErrClosed is the error returned when the connection is closed.
+	ErrClosed = errors.New("ws: connection closed")
+	// ErrMessageTooBig is the error returned when a message exceeds the read limit.
+	ErrMessageTooBig = errors.New("ws: message too big")
+	// ErrProtocol is the error returned when the peer violates the protocol.
+	ErrProtocol = errors.New("ws: protocol error")
+)
+
+// CloseError is the error returned when the peer closes the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("ws: closed with code %d", e.Code)
+	}
+
+	return fmt.Sprintf("ws: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. A single goroutine may read from it while others write:
+// the writes are serialized. The pings are answered while reading, so the connection
+// should be read continuously.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	cfg         Config
+	subprotocol string
+	untrack     func()
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// frame is a frame read from the peer.
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, cfg Config, subprotocol string) *Conn {
+	return &Conn{
+		conn:        conn,
+		br:          br,
+		cfg:         cfg,
+		subprotocol: subprotocol,
+		untrack:     func() {},
+		done:        make(chan struct{}),
+	}
+}
+
+// start starts sending the pings.
+func (c *Conn) start() {
+	if c.cfg.PingInterval > 0 {
+		go c.pingLoop()
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol, or an empty string.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// ReadMessage reads the next data message. Pings are answered and fragmented messages
+// are reassembled. When the peer closes the connection, the close frame is echoed and
+// *CloseError is returned. Protocol violations close the connection with the matching code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ MessageType
+		msg []byte
+	)
+
+	for {
+		f, err := c.readFrame(c.cfg.ReadLimit - int64(len(msg)))
+		switch {
+		case errors.Is(err, ErrMessageTooBig):
+			return 0, nil, c.fail(CloseMessageTooBig, err)
+		case errors.Is(err, ErrProtocol):
+			return 0, nil, c.fail(CloseProtocolError, err)
+		case err != nil:
+			return 0, nil, c.readFailed(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		case opPong:
+			// The read deadline is extended by any frame, pongs included.
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opText, opBinary:
+			if typ != 0 {
+				return 0, nil, c.fail(CloseProtocolError, fmt.Errorf("%w: unfinished fragmented message", ErrProtocol))
+			}
+
+			typ = MessageType(f.opcode)
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(CloseProtocolError, fmt.Errorf("%w: unexpected continuation frame", ErrProtocol))
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Errorf("%w: unknown opcode %d", ErrProtocol, f.opcode))
+		}
+
+		msg = append(msg, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, c.fail(CloseInvalidPayload, fmt.Errorf("%w: invalid UTF-8 in text message", ErrProtocol))
+		}
+
+		return typ, msg, nil
+	}
+}
+
+// readFrame reads a frame whose payload is at most limit bytes, unless it is a control frame.
+func (c *Conn) readFrame(limit int64) (frame, error) {
+	if c.cfg.PingInterval > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(2 * c.cfg.PingInterval))
+	}
+
+	var hdr [maxHeaderLength]byte
+	if _, err := io.ReadFull(c.br, hdr[:2]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    hdr[0]&finBit != 0,
+		opcode: hdr[0] & 0x0f,
+	}
+
+	if hdr[0]&rsvBits != 0 {
+		return frame{}, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+
+	if hdr[1]&maskBit == 0 {
+		return frame{}, fmt.Errorf("%w: unmasked client frame", ErrProtocol)
+	}
+
+	length := uint64(hdr[1] &^ maskBit)
+
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(c.br, hdr[:2]); err != nil {
+			return frame{}, err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, hdr[:8]); err != nil {
+			return frame{}, err
+		}
+
+		length = binary.BigEndian.Uint64(hdr[:8])
+		if length>>63 != 0 {
+			return frame{}, fmt.Errorf("%w: invalid payload length", ErrProtocol)
+		}
+	}
+
+	if f.opcode >= opClose {
+		if !f.fin || length > maxControlPayload {
+			return frame{}, fmt.Errorf("%w: invalid control frame", ErrProtocol)
+		}
+	} else if length > uint64(limit) {
+		return frame{}, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, err
+	}
+
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	return f, nil
+}
+
+// handleClose answers the close frame of the peer and closes the connection.
+func (c *Conn) handleClose(payload []byte) error {
+	code := CloseNoStatus
+	reason := ""
+
+	if len(payload) > 0 {
+		if len(payload) < 2 {
+			return c.fail(CloseProtocolError, fmt.Errorf("%w: invalid close frame", ErrProtocol))
+		}
+
+		code = int(binary.BigEndian.Uint16(payload))
+		reason = string(payload[2:])
+
+		if !validCloseCode(code) || !utf8.ValidString(reason) {
+			return c.fail(CloseProtocolError, fmt.Errorf("%w: invalid close frame", ErrProtocol))
+		}
+	}
+
+	// The close frame is echoed, unless this side has sent one already.
+	var echo []byte
+	if code != CloseNoStatus {
+		echo = payload[:2]
+	}
+
+	_ = c.writeFrame(opClose, echo)
+	c.closeNow()
+
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// validCloseCode reports whether the code may be received in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= CloseNormal && code <= CloseUnsupportedData:
+		return true
+	case code >= CloseInvalidPayload && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
+
+// fail closes the connection with the code and returns err.
+func (c *Conn) fail(code int, err error) error {
+	_ = c.Close(code, "")
+	return err
+}
+
+// readFailed closes the connection after a read error and returns ErrClosed if it was already closed.
+func (c *Conn) readFailed(err error) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	c.closeNow()
+
+	return err
+}
+
+// WriteMessage writes a data message in a single frame.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("ws: invalid message type %d", typ)
+	}
+
+	return c.writeFrame(byte(typ), data)
+}
+
+// Ping sends a ping with the payload of at most 125 bytes.
+func (c *Conn) Ping(payload []byte) error {
+	if len(payload) > maxControlPayload {
+		return fmt.Errorf("ws: ping payload is longer than %d bytes", maxControlPayload)
+	}
+
+	return c.writeFrame(opPing, payload)
+}
+
+// Close sends a close frame with the code and reason, then closes the connection
+// without waiting for the peer to answer. It is safe to call Close more than once.
+func (c *Conn) Close(code int, reason string) error {
+	err := c.writeClose(code, reason)
+	c.closeNow()
+
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// writeClose sends a close frame, unless one has been sent already.
+func (c *Conn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+
+	return c.writeFrame(opClose, payload)
+}
+
+// writeFrame writes a single unmasked frame. Nothing can be written after a close frame.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	buf := make([]byte, 0, maxHeaderLength+len(payload))
+	buf = append(buf, finBit|opcode)
+
+	switch n := len(payload); {
+	case n <= maxControlPayload:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+
+	buf = append(buf, payload...)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+	_, err := c.conn.Write(buf)
+
+	return err
+}
+
+// closeNow closes the underlying connection.
+func (c *Conn) closeNow() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+		c.untrack()
+	})
+}
+
+// pingLoop sends a ping every ping interval until the connection is closed.
+func (c *Conn) pingLoop() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// tracked adapts Conn to httpserver.HijackedConn, so the graceful shutdown closes it with CloseGoingAway.
+type tracked struct {
+	c *Conn
+}
+
+func (t tracked) Shutdown() error {
+	err := t.c.writeClose(CloseGoingAway, "server shutting down")
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Close closes the underlying connection. The pending read fails and finishes the close.
+func (t tracked) Close() error {
+	return t.c.conn.Close()
+}
@@ -0,0 +1,214 @@
+// Package ws implements the server side of the WebSocket protocol, see RFC 6455.
+//
+// Connections upgraded from requests served by an httpserver.Server are closed
+// with the CloseGoingAway code during its graceful shutdown:
+//
+//	func echo(w http.ResponseWriter, r *http.Request) {
+//		c, err := ws.Upgrade(w, r, ws.Config{})
+//		if err != nil {
+//			return
+//		}
+//		defer c.Close(ws.CloseNormal, "")
+//
+//		for {
+//			typ, msg, err := c.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//
+//			if err := c.WriteMessage(typ, msg); err != nil {
+//				return
+//			}
+//		}
+//	}
+package ws
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver"
+)
+
+const (
+	defaultReadLimit    = 1 << 20
+	defaultPingInterval = 30 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+
+	// acceptGUID is appended to the key of the client to compute Sec-WebSocket-Accept.
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	version    = "13"
+	keyLength  = 16
+)
+
+// ErrBadHandshake is the error returned when the request is not a valid WebSocket handshake.
+var ErrBadHandshake = errors.New("ws: bad handshake")
+
+// Config configures a WebSocket connection.
+type Config struct {
+	// ReadLimit limits the size of a message read from the peer. 1 MiB by default.
+	// A larger message closes the connection with CloseMessageTooBig.
+	ReadLimit int64
+	// CheckOrigin reports whether the request is allowed. By default, the Origin header,
+	// if present, must match the Host header.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols are the supported subprotocols, in order of preference.
+	Subprotocols []string
+	// PingInterval is the interval between pings. A peer that sends nothing, pongs included,
+	// for two intervals is considered gone and the read fails. 30 seconds by default,
+	// a negative value disables the pings.
+	PingInterval time.Duration
+	// WriteTimeout limits the duration of a single write. 10 seconds by default.
+	WriteTimeout time.Duration
+}
+
+// Upgrade upgrades the HTTP connection of the request to the WebSocket protocol.
+// If the handshake fails, Upgrade writes an error response, see httpserver.WriteError, and returns the error.
+// The read and write deadlines set by the server are removed from the connection.
+func Upgrade(w http.ResponseWriter, r *http.Request, cfg Config) (*Conn, error) {
+	if cfg.ReadLimit <= 0 {
+		cfg.ReadLimit = defaultReadLimit
+	}
+
+	if cfg.CheckOrigin == nil {
+		cfg.CheckOrigin = sameOrigin
+	}
+
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = defaultPingInterval
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+
+	key, err := checkHandshake(w, r, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	subprotocol := negotiateSubprotocol(r, cfg.Subprotocols)
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		_ = httpserver.WriteError(w, httpserver.NewError(http.StatusInternalServerError, "connection does not support upgrade"))
+		return nil, err
+	}
+
+	// The deadlines of the server apply to HTTP requests, not to the WebSocket connection.
+	_ = netConn.SetDeadline(time.Time{})
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+
+	resp += "\r\n"
+
+	_ = netConn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+	if _, err := brw.WriteString(resp); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	_ = netConn.SetWriteDeadline(time.Time{})
+
+	c := newConn(netConn, brw.Reader, cfg, subprotocol)
+	c.untrack = httpserver.TrackHijacked(r.Context(), tracked{c})
+	c.start()
+
+	return c, nil
+}
+
+// checkHandshake validates the handshake request and returns the key of the client.
+// It writes the error response if the request is invalid.
+func checkHandshake(w http.ResponseWriter, r *http.Request, cfg Config) (string, error) {
+	fail := func(status int, detail string) (string, error) {
+		_ = httpserver.WriteError(w, httpserver.NewError(status, detail))
+		return "", ErrBadHandshake
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		return fail(http.StatusMethodNotAllowed, "")
+	}
+
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket handshake")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != version {
+		w.Header().Set("Sec-WebSocket-Version", version)
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != keyLength {
+		return fail(http.StatusBadRequest, "invalid websocket key")
+	}
+
+	if !cfg.CheckOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	return key, nil
+}
+
+// headerContainsToken reports whether the comma-separated header contains the token, ignoring case.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sameOrigin reports whether the Origin header is missing or matches the Host header.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// negotiateSubprotocol returns the first supported subprotocol requested by the client, or an empty string.
+func negotiateSubprotocol(r *http.Request, supported []string) string {
+	for _, p := range supported {
+		if headerContainsToken(r.Header, "Sec-WebSocket-Protocol", p) {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for the key of the client.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
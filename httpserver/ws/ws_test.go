@@ -0,0 +1,340 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/romankravchuk/nix/httpserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a minimal WebSocket client.
+type client struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial connects to the server at addr and performs the handshake.
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	key := make([]byte, keyLength)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+
+	encoded := base64.StdEncoding.EncodeToString(key)
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: "+addr+"\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: "+encoded+"\r\n\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, acceptKey(encoded), resp.Header.Get("Sec-WebSocket-Accept"))
+
+	return &client{conn: conn, br: br}
+}
+
+// write sends a masked frame.
+func (c *client) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+
+	b0 := opcode
+	if fin {
+		b0 |= finBit
+	}
+
+	buf := []byte{b0}
+
+	switch n := len(payload); {
+	case n <= maxControlPayload:
+		buf = append(buf, maskBit|byte(n))
+	default:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	}
+
+	mask := [4]byte{1, 2, 3, 4}
+	buf = append(buf, mask[:]...)
+
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+
+	_, err := c.conn.Write(buf)
+	require.NoError(t, err)
+}
+
+// read reads an unmasked frame.
+func (c *client) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var hdr [2]byte
+	_, err := io.ReadFull(c.br, hdr[:])
+	require.NoError(t, err)
+
+	length := int(hdr[1])
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(t, err)
+
+	return hdr[0] & 0x0f, payload
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// echoServer starts a server echoing messages and reports the error ending the read loop.
+func echoServer(t *testing.T, cfg Config) (*httptest.Server, <-chan error) {
+	t.Helper()
+
+	errCh := make(chan error, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, cfg)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer c.Close(CloseNormal, "")
+
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			if err := c.WriteMessage(typ, msg); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, errCh
+}
+
+func Test_Upgrade_handshake(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		code    int
+	}{
+		{name: "method", method: http.MethodPost, code: http.StatusMethodNotAllowed},
+		{name: "not upgrade", headers: map[string]string{"Upgrade": ""}, code: http.StatusBadRequest},
+		{name: "version", headers: map[string]string{"Sec-WebSocket-Version": "8"}, code: http.StatusUpgradeRequired},
+		{name: "key", headers: map[string]string{"Sec-WebSocket-Key": "short"}, code: http.StatusBadRequest},
+		{name: "origin", headers: map[string]string{"Origin": "https://evil.example"}, code: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, "http://api.example/ws", nil)
+			req.Header.Set("Connection", "keep-alive, Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			_, err := Upgrade(rec, req, Config{})
+
+			require.ErrorIs(t, err, ErrBadHandshake)
+			assert.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
+
+func Test_acceptKey(t *testing.T) {
+	t.Parallel()
+
+	// The example of RFC 6455, section 1.3.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func Test_Conn_echo(t *testing.T) {
+	t.Parallel()
+
+	srv, errCh := echoServer(t, Config{})
+	c := dial(t, srv.Listener.Addr().String())
+
+	c.write(t, false, opText, []byte("hel"))
+	c.write(t, true, opPing, []byte("p"))
+	c.write(t, true, opContinuation, []byte("lo"))
+
+	op, payload := c.read(t)
+	assert.Equal(t, byte(opPong), op)
+	assert.Equal(t, "p", string(payload))
+
+	op, payload = c.read(t)
+	assert.Equal(t, byte(opText), op)
+	assert.Equal(t, "hello", string(payload))
+
+	c.write(t, true, opClose, closePayload(CloseNormal, "bye"))
+
+	op, payload = c.read(t)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, closePayload(CloseNormal, ""), payload)
+
+	var closeErr *CloseError
+	require.ErrorAs(t, <-errCh, &closeErr)
+	assert.Equal(t, CloseNormal, closeErr.Code)
+	assert.Equal(t, "bye", closeErr.Reason)
+}
+
+func Test_Conn_readLimit(t *testing.T) {
+	t.Parallel()
+
+	srv, errCh := echoServer(t, Config{ReadLimit: 8})
+	c := dial(t, srv.Listener.Addr().String())
+
+	c.write(t, true, opBinary, make([]byte, 16))
+
+	op, payload := c.read(t)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseMessageTooBig, int(binary.BigEndian.Uint16(payload)))
+	require.ErrorIs(t, <-errCh, ErrMessageTooBig)
+}
+
+func Test_Conn_protocolError(t *testing.T) {
+	t.Parallel()
+
+	srv, errCh := echoServer(t, Config{})
+	c := dial(t, srv.Listener.Addr().String())
+
+	c.write(t, true, opContinuation, []byte("orphan"))
+
+	op, payload := c.read(t)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseProtocolError, int(binary.BigEndian.Uint16(payload)))
+	require.ErrorIs(t, <-errCh, ErrProtocol)
+}
+
+func Test_Server_shutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	handlerErr := make(chan error, 1)
+
+	srv := httpserver.New(context.Background(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, Config{})
+		if err != nil {
+			handlerErr <- err
+			return
+		}
+		defer c.Close(CloseNormal, "")
+
+		_, _, err = c.ReadMessage()
+		handlerErr <- err
+	}), httpserver.Listener(ln), httpserver.ShutdownTimeout(5*time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+
+	go func() {
+		runErr <- srv.Run(ctx)
+	}()
+
+	c := dial(t, ln.Addr().String())
+	require.Eventually(t, func() bool { return srv.ConnStats().Hijacked == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+
+	op, payload := c.read(t)
+	require.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseGoingAway, int(binary.BigEndian.Uint16(payload)))
+
+	c.write(t, true, opClose, payload[:2])
+
+	var closeErr *CloseError
+	require.ErrorAs(t, <-handlerErr, &closeErr)
+	assert.Equal(t, CloseGoingAway, closeErr.Code)
+
+	select {
+	case err := <-runErr:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not finish after the connection was closed")
+	}
+
+	assert.Equal(t, 0, srv.ConnStats().Hijacked)
+}
+
+func Test_Server_shutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := httpserver.New(context.Background(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, Config{})
+		if err != nil {
+			return
+		}
+
+		_, _, _ = c.ReadMessage()
+	}), httpserver.Listener(ln), httpserver.ShutdownTimeout(100*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+
+	go func() {
+		runErr <- srv.Run(ctx)
+	}()
+
+	c := dial(t, ln.Addr().String())
+	require.Eventually(t, func() bool { return srv.ConnStats().Hijacked == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+
+	// The client never answers the close frame, so the connection is closed at the timeout.
+	require.ErrorIs(t, <-runErr, context.DeadlineExceeded)
+
+	_, _ = c.read(t)
+	_, err = c.br.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -21,19 +21,33 @@ const (
 // For Local returns Text logger with DEBUG level.
 // For Production returns JSON logger with INFO level.
 func New(w io.Writer, env Env) *slog.Logger {
+	l, json := envToOpts(env)
+
+	return newLogger(w, l, json)
+}
+
+// NewWithLevelVar creates a new logger like New, but its level is held by the returned
+// *slog.LevelVar, so it can be changed at runtime.
+func NewWithLevelVar(w io.Writer, env Env) (*slog.Logger, *slog.LevelVar) {
 	var (
-		s       *slog.Logger
+		lv      = new(slog.LevelVar)
 		l, json = envToOpts(env)
-		opts    = &slog.HandlerOptions{Level: l}
 	)
 
+	lv.Set(l)
+
+	return newLogger(w, lv, json), lv
+}
+
+// newLogger creates a JSON or Text logger with the given level.
+func newLogger(w io.Writer, level slog.Leveler, json bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
 	if json {
-		s = slog.New(slog.NewJSONHandler(w, opts))
-	} else {
-		s = slog.New(slog.NewTextHandler(w, opts))
+		return slog.New(slog.NewJSONHandler(w, opts))
 	}
 
-	return s
+	return slog.New(slog.NewTextHandler(w, opts))
 }
 
 // envToOpts converts the environment to logger options.
@@ -71,25 +71,49 @@ func New() *Validator {
 
 // Validate validates the given 'data' using the validator.
 func (v *Validator) Validate(data interface{}) bool {
+	errs, err := v.Check(data)
+	if err != nil {
+		return false
+	}
+
+	if len(errs) > 0 {
+		v.errsMu.Lock()
+		defer v.errsMu.Unlock()
+
+		for field, msg := range errs {
+			v.errs[field] = msg
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Check validates the given 'data' and returns the validation errors as a map of field names
+// to error messages, or nil if the data is valid. Unlike Validate, it does not keep the errors
+// in the validator, so it is safe to share the validator between goroutines.
+// An error is returned if the data can not be validated, e.g. it is not a struct.
+func (v *Validator) Check(data interface{}) (map[string]string, error) {
 	var (
 		errs validator.ValidationErrors
 		err  = v.validator.Struct(data)
 	)
 
-	if err != nil {
-		if errors.As(err, &errs) {
-			v.errsMu.Lock()
-			defer v.errsMu.Unlock()
+	if err == nil {
+		return nil, nil
+	}
 
-			for _, e := range errs {
-				v.errs[e.Field()] = e.Translate(v.translator)
-			}
-		}
+	if !errors.As(err, &errs) {
+		return nil, err
+	}
 
-		return false
+	fields := make(map[string]string, len(errs))
+	for _, e := range errs {
+		fields[e.Field()] = e.Translate(v.translator)
 	}
 
-	return true
+	return fields, nil
 }
 
 // Errors returns the validation errors as a map of field names to error messages.
@@ -71,3 +71,26 @@ func Test_Validate(t *testing.T) {
 		})
 	}
 }
+
+func Test_Check(t *testing.T) {
+	type user struct {
+		Name  string `validate:"required"`
+		Email string `validate:"required,email"`
+	}
+
+	v := New()
+
+	errs, err := v.Check(user{Name: "john", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Nil(t, errs)
+
+	errs, err = v.Check(user{Email: "john"})
+	require.NoError(t, err)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs, "Name")
+	assert.Contains(t, errs, "Email")
+	assert.Empty(t, v.Errors())
+
+	_, err = v.Check("not a struct")
+	require.Error(t, err)
+}